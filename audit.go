@@ -0,0 +1,251 @@
+package srp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+Audit events record what happened to whom, for an append-only audit trail. They
+are separate from Observer, which is about performance, and are emitted by the
+servers in srphttp and srpchannel when they are given an AuditSink.
+
+An AuditEvent has no place for keys, proofs, verifiers, salts or passwords, so
+a sink can't be handed a secret by mistake.
+
+HashChainSink makes a file of events tamper evident. Each line is
+
+	{"event":{...},"prev":"<hex>","hash":"<hex>"}
+
+where hash is SHA-256(prev | event) over the event's exact bytes, and prev is
+the previous line's hash (all zeros for the first line). Changing, removing or
+reordering lines breaks the chain, which VerifyHashChain detects. Truncating the
+file at a line boundary doesn't, so keep the last hash somewhere else too.
+
+Identities, User-Agents and the like come from clients, so the sinks here cut
+an event's strings to MaxAuditFieldLen bytes before writing it, and a client
+can't make lines of any length it likes.
+*/
+
+// AuditEventType is the kind of an AuditEvent.
+type AuditEventType string
+
+// Kinds of audit event
+const (
+	AuditEnroll          AuditEventType = "enroll"           // an identity was enrolled
+	AuditVerifierChange  AuditEventType = "verifier_change"  // a credential's verifier was changed or added
+	AuditLogin           AuditEventType = "login"            // a client proved itself
+	AuditProofFailed     AuditEventType = "proof_failed"     // a client's proof was wrong
+	AuditMalformedPublic AuditEventType = "malformed_public" // a client sent a bad A
+	AuditLockout         AuditEventType = "lockout"          // an attempt was refused by an AttemptLimiter
+)
+
+// MaxAuditFieldLen is the most bytes of each of an AuditEvent's strings that
+// JSONLinesSink and HashChainSink write.
+const MaxAuditFieldLen = 1024
+
+// AuditEvent is one entry in an audit trail.
+type AuditEvent struct {
+	Time       time.Time      `json:"time"`
+	Type       AuditEventType `json:"type"`
+	Identity   string         `json:"identity,omitempty"`
+	Credential string         `json:"credential,omitempty"`
+	Group      string         `json:"group,omitempty"` // label
+	KDF        *KDFParams     `json:"kdf,omitempty"`   // the record's KDF profile
+	Source     string         `json:"source,omitempty"`
+	Client     string         `json:"client,omitempty"` // client metadata, such as a User-Agent
+	Reason     string         `json:"reason,omitempty"`
+	Fake       bool           `json:"fake,omitempty"` // the record was made up by a FakeChallenger
+}
+
+// truncated returns a copy of ev with its strings cut to MaxAuditFieldLen bytes.
+func (ev *AuditEvent) truncated() *AuditEvent {
+	cp := *ev
+	for _, f := range []*string{&cp.Identity, &cp.Credential, &cp.Group, &cp.Source, &cp.Client, &cp.Reason} {
+		*f = truncateUTF8(*f, MaxAuditFieldLen)
+	}
+	return &cp
+}
+
+// truncateUTF8 cuts s to at most n bytes, without splitting a UTF-8 sequence.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// AuditSink receives audit events. It must be safe for concurrent use.
+type AuditSink interface {
+	Audit(ctx context.Context, ev *AuditEvent) error
+}
+
+// JSONLinesSink is an AuditSink that writes each event to a writer as a line of JSON.
+type JSONLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ AuditSink = &JSONLinesSink{} //nolint:exhaustruct
+
+// NewJSONLinesSink returns a JSONLinesSink writing to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// Audit implements AuditSink.
+func (s *JSONLinesSink) Audit(_ context.Context, ev *AuditEvent) error {
+	line, err := json.Marshal(ev.truncated())
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// HashChainSink is an AuditSink that appends events to a file as a hash chain.
+type HashChainSink struct {
+	mu   sync.Mutex
+	f    *os.File
+	prev [sha256.Size]byte
+}
+
+var _ AuditSink = &HashChainSink{} //nolint:exhaustruct
+
+type chainedLine struct {
+	Event json.RawMessage `json:"event"`
+	Prev  string          `json:"prev"`
+	Hash  string          `json:"hash"`
+}
+
+// OpenHashChainSink opens the hash chained audit file at path, creating it if
+// needed. An existing file is verified, and new events continue its chain.
+func OpenHashChainSink(path string) (*HashChainSink, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s := &HashChainSink{f: f}
+	if s.prev, err = verifyHashChain(f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Audit implements AuditSink. The event is synced to disk before Audit returns.
+func (s *HashChainSink) Audit(_ context.Context, ev *AuditEvent) error {
+	event, err := json.Marshal(ev.truncated())
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash := chainHash(s.prev, event)
+	line, err := json.Marshal(&chainedLine{
+		Event: event,
+		Prev:  hex.EncodeToString(s.prev[:]),
+		Hash:  hex.EncodeToString(hash[:]),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	s.prev = hash
+	return nil
+}
+
+// LastHash returns the hash of the last event written, which a reader can use to
+// check that the file hasn't been truncated.
+func (s *HashChainSink) LastHash() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return hex.EncodeToString(s.prev[:])
+}
+
+// Close closes the file.
+func (s *HashChainSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// ErrBrokenHashChain is returned when a hash chained audit file has been tampered with.
+var ErrBrokenHashChain = errors.New("audit hash chain is broken")
+
+// VerifyHashChain checks a file written by HashChainSink and returns the hash of its last line.
+func VerifyHashChain(r io.Reader) (string, error) {
+	last, err := verifyHashChain(r)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(last[:]), nil
+}
+
+func verifyHashChain(r io.Reader) ([sha256.Size]byte, error) {
+	var prev [sha256.Size]byte
+	br := bufio.NewReader(r)
+	// Lines are read whole, however long, so that a long one written before
+	// events were truncated doesn't stop the file being opened.
+	for n := 1; ; n++ {
+		text, err := br.ReadBytes('\n')
+		if len(text) == 0 {
+			if errors.Is(err, io.EOF) {
+				return prev, nil
+			}
+			return prev, err
+		}
+		var line chainedLine
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&line); err != nil {
+			return prev, fmt.Errorf("%w: line %d: %v", ErrBrokenHashChain, n, err)
+		}
+		if line.Prev != hex.EncodeToString(prev[:]) {
+			return prev, fmt.Errorf("%w: line %d doesn't follow the one before", ErrBrokenHashChain, n)
+		}
+		hash := chainHash(prev, line.Event)
+		if line.Hash != hex.EncodeToString(hash[:]) {
+			return prev, fmt.Errorf("%w: line %d has the wrong hash", ErrBrokenHashChain, n)
+		}
+		prev = hash
+		if err != nil && !errors.Is(err, io.EOF) {
+			return prev, err
+		}
+	}
+}
+
+func chainHash(prev [sha256.Size]byte, event []byte) [sha256.Size]byte {
+	h := sha256.New()
+	_, _ = h.Write(prev[:])
+	_, _ = h.Write(event)
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
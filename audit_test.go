@@ -0,0 +1,156 @@
+package srp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestJSONLinesSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONLinesSink(&buf)
+	ev := &AuditEvent{Time: time.Unix(1600000000, 0).UTC(), Type: AuditLogin, Identity: "alice", Group: "5054A2048"}
+	for i := 0; i < 2; i++ {
+		if err := sink.Audit(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines", len(lines))
+	}
+	var got AuditEvent
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != *ev {
+		t.Errorf("got %+v, want %+v", got, *ev)
+	}
+}
+
+func TestHashChainSink(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	sink, err := OpenHashChainSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []AuditEventType{AuditEnroll, AuditProofFailed, AuditLogin, AuditVerifierChange}
+	for _, typ := range types[:2] {
+		if err := sink.Audit(ctx, &AuditEvent{Type: typ, Identity: "alice", Reason: `<"odd">`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = sink.Close()
+
+	// Reopening continues the chain.
+	if sink, err = OpenHashChainSink(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range types[2:] {
+		if err := sink.Audit(ctx, &AuditEvent{Type: typ, Identity: "alice"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last := sink.LastHash()
+	_ = sink.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := VerifyHashChain(bytes.NewReader(data)); err != nil || got != last {
+		t.Fatalf("VerifyHashChain = %s, %v; want %s", got, err, last)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	tampered := map[string]string{
+		"changed":   strings.Join(lines[:1], "") + strings.Replace(lines[1], "alice", "mallory", 1) + strings.Join(lines[2:], ""),
+		"removed":   lines[0] + strings.Join(lines[2:], ""),
+		"reordered": lines[1] + lines[0] + strings.Join(lines[2:], ""),
+	}
+	for name, text := range tampered {
+		if _, err := VerifyHashChain(strings.NewReader(text)); !errors.Is(err, ErrBrokenHashChain) {
+			t.Errorf("%s: expected ErrBrokenHashChain, got %v", name, err)
+		}
+	}
+	if err := ioutil.WriteFile(path, []byte(tampered["changed"]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenHashChainSink(path); !errors.Is(err, ErrBrokenHashChain) {
+		t.Errorf("opened tampered file: %v", err)
+	}
+}
+
+func TestHashChainSinkLongFields(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	// Each < is escaped to six bytes, so untruncated this line would be over 1 MiB.
+	sink, err := OpenHashChainSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &AuditEvent{Type: AuditLogin, Identity: strings.Repeat("é", 1000), Client: strings.Repeat("<", 200<<10)}
+	if err := sink.Audit(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(ev.Client) != 200<<10 {
+		t.Error("Audit changed the caller's event")
+	}
+	_ = sink.Close()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 16*MaxAuditFieldLen {
+		t.Errorf("line of %d bytes", len(data))
+	}
+	var line struct{ Event AuditEvent }
+	if err := json.Unmarshal(data, &line); err != nil {
+		t.Fatal(err)
+	}
+	if len(line.Event.Client) != MaxAuditFieldLen || !utf8.ValidString(line.Event.Identity) || len(line.Event.Identity) > MaxAuditFieldLen {
+		t.Errorf("client of %d bytes, identity of %d", len(line.Event.Client), len(line.Event.Identity))
+	}
+	if sink, err = OpenHashChainSink(path); err != nil {
+		t.Fatalf("reopening: %s", err)
+	}
+	_ = sink.Close()
+
+	// A line longer than any buffer, written before events were truncated, is still read.
+	event := []byte(`{"type":"login","client":"` + strings.Repeat(`\u003c`, 200<<10) + `"}`)
+	hash := chainHash([32]byte{}, event)
+	long, err := json.Marshal(&chainedLine{Event: event, Prev: strings.Repeat("0", 64), Hash: hex.EncodeToString(hash[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := VerifyHashChain(bytes.NewReader(append(long, '\n'))); err != nil || got != hex.EncodeToString(hash[:]) {
+		t.Errorf("VerifyHashChain of a %d byte line = %s, %v", len(long), got, err)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
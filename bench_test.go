@@ -0,0 +1,153 @@
+package srp
+
+import (
+	"math/big"
+	"testing"
+)
+
+var benchX = NumberFromString("0x 1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef")
+
+// handshake runs a whole exchange in grp, from creating both sides to checking both proofs.
+func handshake(tb testing.TB, p profile, grp *Group, x, v *big.Int) {
+	client, server := keyedPair(tb, p, grp, x, v)
+	salt := []byte("salt")
+	m, err := server.M(salt, "alice")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if !client.GoodServerProof(salt, "alice", m) {
+		tb.Fatal("bad server proof")
+	}
+	proof, err := client.ClientProof()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if !server.GoodClientProof(proof) {
+		tb.Fatal("bad client proof")
+	}
+}
+
+// TestHandshakeAllocs guards against the hot path going back to allocating
+// for every intermediate value. A handshake made 324 allocations before scratch
+// space and makes about 67 now; big.Int's Exp accounts for 60 of them, which is
+// why the aim of a tenth isn't met (see scratch.go).
+func TestHandshakeAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations aren't representative with the race detector")
+	}
+	grp := KnownGroups[RFC5054Group2048]
+	for _, p := range profiles {
+		v, err := p.newClient(grp, benchX).Verifier()
+		if err != nil {
+			t.Fatal(err)
+		}
+		handshake(t, p, grp, benchX, v) // build the table
+		if allocs := testing.AllocsPerRun(10, func() { handshake(t, p, grp, benchX, v) }); allocs > 72 {
+			t.Errorf("%s handshake made %v allocations", p.name, allocs)
+		}
+	}
+}
+
+/*
+BenchmarkHandshake measures a whole exchange, client and server, in each of the
+KnownGroups and each profile: "Exp" with big.Int's Exp for every exponentiation,
+as before the groups had tables, and "tables" as things are.
+*/
+func BenchmarkHandshake(b *testing.B) {
+	for _, id := range knownGroupIDs() {
+		if KnownGroups[id].fixedBase == nil {
+			continue // added by a test
+		}
+		withoutTables := *KnownGroups[id]
+		withoutTables.fixedBase = nil
+		for _, p := range profiles {
+			for _, bc := range []struct {
+				name string
+				grp  *Group
+			}{
+				{"Exp", &withoutTables},
+				{"tables", KnownGroups[id]},
+			} {
+				p, grp := p, bc.grp
+				v, err := p.newClient(grp, benchX).Verifier()
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.Run(grp.Label+"/"+p.name+"/"+bc.name, func(b *testing.B) {
+					handshake(b, p, grp, benchX, v) // build the table
+					b.ReportAllocs()
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						handshake(b, p, grp, benchX, v)
+					}
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkSteps measures the steps of a handshake separately, in the 2048 bit group.
+func BenchmarkSteps(b *testing.B) {
+	grp := KnownGroups[RFC5054Group2048]
+	for _, p := range profiles {
+		p := p
+		v, err := p.newClient(grp, benchX).Verifier()
+		if err != nil {
+			b.Fatal(err)
+		}
+		client, server := keyedPair(b, p, grp, benchX, v)
+		b.Run(p.name+"/NewClient", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p.newClient(grp, benchX)
+			}
+		})
+		b.Run(p.name+"/NewServer", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p.newServer(grp, v)
+			}
+		})
+		b.Run(p.name+"/calculateU", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.calculateU(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		for _, side := range []*SRP{client, server} {
+			side := side
+			b.Run(p.name+"/Key/"+side.role(), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					side.key = nil
+					if _, err := side.Key(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+		b.Run(p.name+"/M", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				server.m = nil
+				if _, err := server.M([]byte("salt"), "alice"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+	b.Run("PaddedBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		x := new(big.Int).Rsh(grp.n, 1)
+		for i := 0; i < b.N; i++ {
+			grp.PaddedBytes(x)
+		}
+	})
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
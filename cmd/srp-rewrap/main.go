@@ -0,0 +1,130 @@
+/*
+Command srp-rewrap reseals every verifier in an srpsql database under a new key.
+
+Usage:
+
+	srp-rewrap -driver postgres -dsn 'postgres://...' -keys keys.json
+
+The keys file names the current key and holds every key that records may
+currently be sealed under, base64 encoded:
+
+	{
+		"current": "2022-06",
+		"keys": {
+			"2022-01": "...",
+			"2022-06": "..."
+		}
+	}
+
+Records sealed under an older key, and records that were never sealed, are
+resealed under the current key. Once it has run successfully the older keys
+are no longer needed.
+
+The schema is left alone unless -migrate is given, in which case it is created
+or upgraded first, as srpsql's Migrate does.
+*/
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/1Password/srp"
+	"github.com/1Password/srp/srpsql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type keyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	driver := flag.String("driver", "postgres", "database/sql driver: postgres or sqlite3")
+	dsn := flag.String("dsn", "", "data source name for the database")
+	keysPath := flag.String("keys", "", "path to the JSON keys file")
+	migrate := flag.Bool("migrate", false, "create or upgrade the schema before rewrapping")
+	flag.Parse()
+
+	if *dsn == "" || *keysPath == "" {
+		flag.Usage()
+		return fmt.Errorf("both -dsn and -keys are required")
+	}
+
+	var dialect srpsql.Dialect
+	switch *driver {
+	case "postgres":
+		dialect = srpsql.Postgres
+	case "sqlite3":
+		dialect = srpsql.SQLite
+	default:
+		return fmt.Errorf("unsupported driver %q", *driver)
+	}
+
+	sealer, err := loadSealer(*keysPath)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open(*driver, *dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	store, err := srpsql.NewStore(db, dialect)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if *migrate {
+		if err := store.Migrate(ctx); err != nil {
+			return err
+		}
+	}
+
+	n, err := srp.Rewrap(ctx, store, sealer)
+	if err != nil {
+		return fmt.Errorf("rewrapped %d records before failing: %w", n, err)
+	}
+	fmt.Printf("rewrapped %d records under key %q\n", n, sealer.CurrentKeyID())
+	return nil
+}
+
+func loadSealer(path string) (*srp.VerifierSealer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("failed to parse keys: %w", err)
+	}
+	keys := make(map[string][]byte, len(kf.Keys))
+	for id, encoded := range kf.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return srp.NewVerifierSealer(kf.Current, keys)
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
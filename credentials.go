@@ -0,0 +1,132 @@
+package srp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+/*
+An identity can hold several credentials, each a VerifierRecord with its own salt,
+KDF, group and revocation state. A typical account might have
+
+	""                 the primary password (PrimaryCredential)
+	"recovery-1" ...   printed recovery codes
+	"device-<id>"      a high entropy key held by one device
+
+In a handshake the client names the credential it is using along with I and A.
+The server fetches it with LookupCredential, which treats revoked credentials
+exactly like ones that never existed. Either way the server must carry on as
+though the credential exists, so that an unauthenticated party can't learn which
+credentials an identity has from how the server responds. The handshake then
+fails at the proof. FakeChallenger.Lookup does this.
+
+Once authenticated with any credential, a client can add another with
+SealNewCredential and AddCredential.
+*/
+
+// newCredentialLabel is used with sealWithSessionKey.
+const newCredentialLabel = "SRP new credential v1"
+
+// ErrInvalidName is returned for an identity or credential name with a zero
+// byte in it, which can't be sealed for.
+var ErrInvalidName = errors.New("identity or credential name contains a zero byte")
+
+/*
+credentialName combines an identity and credential into the name that things are
+sealed for. The primary credential is named by the identity alone, and others by
+the identity, a zero byte and the credential. Neither part may contain a zero
+byte, or "a\x00b" with the primary credential would be named the same as "a" with
+credential "b", and a verifier sealed for one would open as the other's.
+*/
+func credentialName(identity, credential string) (string, error) {
+	if strings.IndexByte(identity, 0) >= 0 || strings.IndexByte(credential, 0) >= 0 {
+		return "", ErrInvalidName
+	}
+	if credential == PrimaryCredential {
+		return identity, nil
+	}
+	return identity + "\x00" + credential, nil
+}
+
+// LookupCredential returns the record for a credential that a client has named
+// in a handshake. Revoked credentials are reported as ErrUnknownIdentity, just as
+// missing ones are.
+func LookupCredential(ctx context.Context, store VerifierStore, identity, credential string) (*VerifierRecord, error) {
+	rec, err := store.Lookup(ctx, identity, credential)
+	if err != nil {
+		return nil, err
+	}
+	if rec.IsRevoked() {
+		return nil, ErrUnknownIdentity
+	}
+	return rec, nil
+}
+
+// RevokeCredential marks a credential as revoked at the given time.
+// Revoking an already revoked credential leaves its revocation time alone.
+func RevokeCredential(ctx context.Context, store VerifierStore, identity, credential string, at time.Time) error {
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		rec, err := store.Lookup(ctx, identity, credential)
+		if err != nil {
+			return err
+		}
+		if rec.IsRevoked() {
+			return nil
+		}
+		rec.RevokedAt = at
+		err = store.Update(ctx, rec)
+		if !errors.Is(err, ErrVersionConflict) {
+			return err
+		}
+	}
+	return ErrVersionConflict
+}
+
+// SealNewCredential is called by a client to add a credential to identity.
+// It may only be called once the server has proved itself.
+func (s *SRP) SealNewCredential(identity, credential string, salt []byte, groupID int, kdf KDFParams, v *big.Int) ([]byte, error) {
+	if credential == PrimaryCredential {
+		return nil, fmt.Errorf("the primary credential can't be added")
+	}
+	name, err := credentialName(identity, credential)
+	if err != nil {
+		return nil, err
+	}
+	return s.sealCredentialChange(newCredentialLabel, name, salt, groupID, kdf, v)
+}
+
+/*
+AddCredential opens a new credential sealed with SealNewCredential and enrolls it
+in store. server must have authenticated the client as rec.Identity, with any
+credential. Like ChangePassword it can be used at most once per session.
+
+It returns ErrIdentityExists if the credential name is in use, including by a
+revoked credential.
+*/
+func AddCredential(ctx context.Context, store VerifierStore, server *SRP, rec *VerifierRecord, credential string, sealed []byte) (*VerifierRecord, error) {
+	if credential == PrimaryCredential {
+		return nil, fmt.Errorf("the primary credential can't be added")
+	}
+	name, err := credentialName(rec.Identity, credential)
+	if err != nil {
+		return nil, err
+	}
+	added, err := server.openCredentialChange(newCredentialLabel, rec.Identity, name, sealed)
+	if err != nil {
+		return nil, err
+	}
+	added.Credential = credential
+	if err := store.Enroll(ctx, added); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
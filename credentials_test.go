@@ -0,0 +1,95 @@
+package srp
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestAddAndRevokeCredential(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	identity := "alice"
+	rec := enroll(t, store, identity, "password123", RFC5054Group2048)
+
+	client, server := authenticatedPair(t, rec, "password123")
+
+	// A device credential. Its "password" is a high entropy key.
+	deviceKey := "b1e6a1b1e5d0bf5d6fa0c1a0e6bb2a5c"
+	deviceSalt := []byte("device salt")
+	deviceV, err := NewClientStd(KnownGroups[RFC5054Group3072], KDFRFC5054(deviceSalt, identity, deviceKey)).Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := client.SealNewCredential(identity, "device-1", deviceSalt, RFC5054Group3072, KDFParams{}, deviceV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The credential name is bound to what the client sealed
+	if _, err := AddCredential(ctx, store, server, rec, "device-2", sealed); err == nil {
+		t.Error("added credential under a different name")
+	}
+	device, err := AddCredential(ctx, store, server, rec, "device-1", sealed)
+	if err != nil {
+		t.Fatalf("failed to add credential: %s", err)
+	}
+
+	names, _ := store.Credentials(ctx, identity)
+	if len(names) != 2 || names[0] != PrimaryCredential || names[1] != "device-1" {
+		t.Errorf("unexpected credentials %q", names)
+	}
+
+	found, err := LookupCredential(ctx, store, identity, "device-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Verifier.Cmp(device.Verifier) != 0 {
+		t.Error("looked up wrong credential")
+	}
+	authenticatedPair(t, found, deviceKey)
+
+	if err := RevokeCredential(ctx, store, identity, "device-1", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	_, revokedErr := LookupCredential(ctx, store, identity, "device-1")
+	_, missingErr := LookupCredential(ctx, store, identity, "device-99")
+	if !errors.Is(revokedErr, ErrUnknownIdentity) || !errors.Is(missingErr, ErrUnknownIdentity) {
+		t.Errorf("revoked and missing credentials look different: %v, %v", revokedErr, missingErr)
+	}
+
+	// The primary credential is unaffected
+	if _, err := LookupCredential(ctx, store, identity, PrimaryCredential); err != nil {
+		t.Errorf("primary credential affected by revocation: %s", err)
+	}
+}
+
+func TestSealedCredentialsAreDistinct(t *testing.T) {
+	vs := testSealer(t, "k1", "k1")
+	primary := &VerifierRecord{Identity: "alice", Verifier: NumberFromString("0x1234")}
+	if err := vs.SealRecord(primary); err != nil {
+		t.Fatal(err)
+	}
+
+	// Moving a sealed verifier to another credential must not work
+	moved := &VerifierRecord{Identity: "alice", Credential: "recovery-1", SealedVerifier: primary.SealedVerifier}
+	if err := vs.OpenRecord(moved); err == nil {
+		t.Error("opened sealed verifier under another credential")
+	}
+
+	// Nor to a primary credential whose identity runs the two names together
+	moved = &VerifierRecord{Identity: "alice\x00recovery-1", SealedVerifier: primary.SealedVerifier}
+	if err := vs.OpenRecord(moved); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("expected ErrInvalidName, got %v", err)
+	}
+	if err := vs.SealRecord(&VerifierRecord{Identity: "alice", Credential: "a\x00b", Verifier: big.NewInt(2)}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("expected ErrInvalidName sealing, got %v", err)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -151,6 +151,10 @@ func Example() {
 	// Once you have confirmed that client and server are using the same key
 	// (thus proving that x and v have the right relation to each other)
 	// we can use that key to encrypt stuff.
+	//
+	// This shows what is going on underneath. Real code should use the
+	// srpchannel package, which derives a key for each direction and takes
+	// care of nonces.
 
 	// Let's have it be a missive from the server to the client
 
@@ -0,0 +1,84 @@
+package srp
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+Key is a single hash of the premaster secret, and using it directly for more
+than one purpose (say, encryption in both directions) is asking for trouble.
+ExportKeyingMaterial derives any number of independent keys from a session, in
+the style of the TLS exporter of RFC 5705:
+
+	PRK = HKDF-Extract(salt = SHA-256(N | g | A | B), IKM = PAD(premaster secret))
+	OKM = HKDF-Expand(PRK, info, length)
+
+where info is "SRP exporter v1", then the label and context each preceded by its
+length, then the length of the output, all lengths being big endian uint32s.
+A nil context is distinct from an empty one, as in RFC 5705; the
+context length is 0xFFFFFFFF if it is nil.
+
+Both parties derive the same material for the same label, context and length.
+Labels should be distinct for distinct purposes, such as
+"client to server encryption" and "server to client encryption".
+*/
+
+const (
+	exporterLabel = "SRP exporter v1"
+
+	// MaxExportLength is the most keying material that can be exported
+	// for one label and context.
+	MaxExportLength = 255 * sha256.Size
+)
+
+// ExportKeyingMaterial returns length bytes of keying material for label and context.
+// It may only be called once the other party has proved knowledge of the key,
+// by GoodServerProof on the client or GoodClientProof on the server.
+func (s *SRP) ExportKeyingMaterial(label string, context []byte, length int) ([]byte, error) {
+	if s.isServer && !s.isClientProved {
+		return nil, fmt.Errorf("don't export keying material until client is proved")
+	}
+	if !s.isServer && !s.isServerProved {
+		return nil, fmt.Errorf("don't export keying material until server is proved")
+	}
+	if s.key == nil || s.premasterKey == nil || s.premasterKey.Sign() == 0 {
+		return nil, fmt.Errorf("no key to export from")
+	}
+	if length < 1 || length > MaxExportLength {
+		return nil, fmt.Errorf("can't export %d bytes", length)
+	}
+
+	transcript := sha256.New()
+	transcript.Write(s.group.n.Bytes())
+	transcript.Write(s.group.PaddedBytes(s.group.g))
+	transcript.Write(s.group.PaddedBytes(s.ephemeralPublicA))
+	transcript.Write(s.group.PaddedBytes(s.ephemeralPublicB))
+	prk := hkdfExtract(transcript.Sum(nil), s.group.PaddedBytes(s.premasterKey))
+
+	info := make([]byte, 0, len(exporterLabel)+len(label)+len(context)+12)
+	info = append(info, exporterLabel...)
+	info = appendUint32(info, uint32(len(label)))
+	info = append(info, label...)
+	if context == nil {
+		info = appendUint32(info, 0xFFFFFFFF)
+	} else {
+		info = appendUint32(info, uint32(len(context)))
+		info = append(info, context...)
+	}
+	info = appendUint32(info, uint32(length))
+
+	return hkdfExpand(prk, info, length), nil
+}
+
+func appendUint32(b []byte, n uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], n)
+	return append(b, buf[:]...)
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,70 @@
+package srp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestHKDF checks against test case 1 of RFC 5869 Appendix A.
+func TestHKDF(t *testing.T) {
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	expected := "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+
+	okm := hkdfExpand(hkdfExtract(salt, ikm), info, 42)
+	if hex.EncodeToString(okm) != expected {
+		t.Errorf("HKDF mismatch:\n got %x\nwant %s", okm, expected)
+	}
+}
+
+func TestExportKeyingMaterial(t *testing.T) {
+	store := NewMemoryStore()
+	rec := enroll(t, store, "alice", "password123", RFC5054Group2048)
+
+	unproved := NewClientStd(rec.Group(), KDFRFC5054(rec.Salt, rec.Identity, "password123"))
+	if _, err := unproved.ExportKeyingMaterial("test", nil, 32); err == nil {
+		t.Error("exported keying material before server was proved")
+	}
+
+	client, server := authenticatedPair(t, rec, "password123")
+
+	c2s, err := client.ExportKeyingMaterial("client to server", nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2c, err := server.ExportKeyingMaterial("server to client", nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(c2s, s2c) {
+		t.Error("different labels gave the same material")
+	}
+	if serverC2S, _ := server.ExportKeyingMaterial("client to server", nil, 32); !bytes.Equal(c2s, serverC2S) {
+		t.Error("client and server exported different material")
+	}
+
+	empty, _ := client.ExportKeyingMaterial("client to server", []byte{}, 32)
+	if bytes.Equal(c2s, empty) {
+		t.Error("nil and empty context gave the same material")
+	}
+	long, err := client.ExportKeyingMaterial("client to server", nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(long) != 100 || bytes.Equal(long[:32], c2s) {
+		t.Error("length isn't bound into the material")
+	}
+	if key, _ := client.Key(); bytes.Equal(key, c2s) {
+		t.Error("exported material is the session key")
+	}
+	if _, err := client.ExportKeyingMaterial("too long", nil, MaxExportLength+1); err == nil {
+		t.Error("exported more than MaxExportLength")
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,201 @@
+package srp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+/*
+A server that only answers for identities it knows tells anyone who asks which
+identities exist. To avoid that, the server should answer a ClientHello for an
+unknown identity (or credential) exactly as it would for a known one, with a salt,
+KDF parameters and a B, and let the handshake fail at the proof.
+
+FakeChallenger makes that easy. From a server secret it derives, for any identity,
+a fake VerifierRecord: the same salt every time for the same identity, the
+KDF parameters and group that real records are enrolled with, and a verifier
+that nobody knows the discrete log of. A server created from it with
+NewServerFromRecord computes B just as it would for a real record, and its
+GoodClientProof never succeeds.
+
+A client that asks for the wrong group is told the record's group, so if real
+records are spread over several groups the fake ones must be too, in the same
+proportions, or asking in each group would tell real identities from unknown
+ones. SetGroupWeights does that.
+*/
+
+// MinFakeSecretSize is the minimum size (in bytes) of the secret for a FakeChallenger.
+const MinFakeSecretSize = 32
+
+// fakeExtractSalt is the HKDF-Extract salt for the secret of a FakeChallenger.
+const fakeExtractSalt = "SRP fake records v1"
+
+// FakeChallenger derives fake verifier records for unknown identities.
+type FakeChallenger struct {
+	prk     []byte // HKDF-Extract of the secret
+	groups  []groupWeight
+	total   uint64 // of the weights in groups
+	kdf     KDFParams
+	saltLen int
+}
+
+type groupWeight struct {
+	groupID int
+	weight  uint64
+}
+
+// NewFakeChallenger creates a FakeChallenger. secret must be kept secret and stay the
+// same across restarts and server instances, otherwise the fake salts change and
+// give the game away. groupID, kdf and saltLen should match the records enrolled
+// most recently.
+func NewFakeChallenger(secret []byte, groupID int, kdf KDFParams, saltLen int) (*FakeChallenger, error) {
+	if len(secret) < MinFakeSecretSize {
+		return nil, fmt.Errorf("secret must be at least %d bytes", MinFakeSecretSize)
+	}
+	if KnownGroups[groupID] == nil {
+		return nil, fmt.Errorf("unknown group %d", groupID)
+	}
+	if saltLen < 1 || saltLen > MaxSaltSize {
+		return nil, fmt.Errorf("salt length must be from 1 to %d", MaxSaltSize)
+	}
+	return &FakeChallenger{
+		prk:     hkdfExtract([]byte(fakeExtractSalt), secret),
+		groups:  []groupWeight{{groupID: groupID, weight: 1}},
+		total:   1,
+		kdf:     kdf,
+		saltLen: saltLen,
+	}, nil
+}
+
+/*
+SetGroupWeights spreads fake records over groups in proportion to weights, which
+maps group IDs to, say, the number of real records enrolled in each. Each fake
+record's group is chosen from the secret, so it is the same every time. Without
+it every fake record is in the group given to NewFakeChallenger.
+
+It must be called before fc is used, and changing the weights later moves some
+fake records to other groups.
+*/
+func (fc *FakeChallenger) SetGroupWeights(weights map[int]int) error {
+	groups := make([]groupWeight, 0, len(weights))
+	var total uint64
+	for id, weight := range weights {
+		if KnownGroups[id] == nil {
+			return fmt.Errorf("unknown group %d", id)
+		}
+		if weight < 0 {
+			return fmt.Errorf("negative weight for group %d", id)
+		}
+		if weight > 0 {
+			groups = append(groups, groupWeight{groupID: id, weight: uint64(weight)})
+			total += uint64(weight)
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("no group has a weight")
+	}
+	// The same weights must always give the same choices.
+	sort.Slice(groups, func(i, j int) bool { return groups[i].groupID < groups[j].groupID })
+	fc.groups, fc.total = groups, total
+	return nil
+}
+
+// groupID returns the group of the fake record for the credential of identity.
+func (fc *FakeChallenger) groupID(identity, credential string) int {
+	if len(fc.groups) == 1 {
+		return fc.groups[0].groupID
+	}
+	// The bias from reducing 64 bits mod a total this small doesn't matter.
+	n := binary.BigEndian.Uint64(fc.expand("group", identity, credential, 8)) % fc.total
+	for _, g := range fc.groups {
+		if n < g.weight {
+			return g.groupID
+		}
+		n -= g.weight
+	}
+	panic("fake group weights don't add up")
+}
+
+// Record returns the fake record for the credential of identity.
+// It returns the same record every time it is called with the same arguments.
+func (fc *FakeChallenger) Record(identity, credential string) *VerifierRecord {
+	groupID := fc.groupID(identity, credential)
+	grp := KnownGroups[groupID]
+
+	// The extra bytes make the reduction mod N close enough to uniform.
+	vBytes := fc.expand("verifier", identity, credential, len(grp.n.Bytes())+16)
+	v := grp.Reduce(new(big.Int).SetBytes(vBytes))
+	if v.Cmp(bigOne) <= 0 {
+		// Vanishingly unlikely, but we must have something that makeB accepts.
+		v.SetInt64(2)
+	}
+
+	return &VerifierRecord{
+		Identity:   identity,
+		Credential: credential,
+		Salt:       fc.expand("salt", identity, credential, fc.saltLen),
+		GroupID:    groupID,
+		KDF:        fc.kdf,
+		Verifier:   v,
+		Version:    1,
+		fake:       true,
+	}
+}
+
+// Lookup is LookupCredential, except that where that would report ErrUnknownIdentity,
+// Lookup returns a fake record instead. Any other error from store is returned.
+func (fc *FakeChallenger) Lookup(ctx context.Context, store VerifierStore, identity, credential string) (*VerifierRecord, error) {
+	rec, err := LookupCredential(ctx, store, identity, credential)
+	if errors.Is(err, ErrUnknownIdentity) {
+		return fc.Record(identity, credential), nil
+	}
+	return rec, err
+}
+
+/*
+expand returns n bytes derived from the secret for label and the credential of
+identity, with HKDF-Expand. The info is label, a zero byte, the length of identity
+as four big endian bytes, identity and credential. Labels have no zero bytes, and
+the length keeps apart identities and credentials that would run together, since
+a client may send any name it likes, zero bytes included.
+*/
+func (fc *FakeChallenger) expand(label, identity, credential string, n int) []byte {
+	info := make([]byte, 0, len(label)+5+len(identity)+len(credential))
+	info = append(info, label...)
+	info = append(info, 0)
+	var idLen [4]byte
+	binary.BigEndian.PutUint32(idLen[:], uint32(len(identity)))
+	info = append(info, idLen[:]...)
+	info = append(info, identity...)
+	info = append(info, credential...)
+	return hkdfExpand(fc.prk, info, n)
+}
+
+/*
+NewServerFromRecord creates a server for the record of the identity that the client
+claims to be, real or fake. It is NewServerStd with the group and verifier of rec.
+If rec is fake, GoodClientProof never succeeds, but everything else proceeds as normal.
+
+Returns nil on error.
+*/
+func NewServerFromRecord(rec *VerifierRecord) *SRP {
+	grp := rec.Group()
+	if grp == nil || rec.Verifier == nil {
+		return nil
+	}
+	s := NewServerStd(grp, rec.Verifier)
+	if s == nil {
+		return nil
+	}
+	s.isFake = rec.fake
+	return s
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
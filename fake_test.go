@@ -0,0 +1,131 @@
+package srp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestFakeChallenger(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	enrolled := enroll(t, store, "alice", "password123", RFC5054Group2048)
+
+	fc, err := NewFakeChallenger(bytes.Repeat([]byte{7}, MinFakeSecretSize), RFC5054Group2048, enrolled.KDF, len(enrolled.Salt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := fc.Lookup(ctx, store, "alice", PrimaryCredential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.fake || found.Verifier.Cmp(enrolled.Verifier) != 0 {
+		t.Error("got fake record for enrolled identity")
+	}
+
+	fake, err := fc.Lookup(ctx, store, "mallory", PrimaryCredential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again := fc.Record("mallory", PrimaryCredential)
+	if !bytes.Equal(fake.Salt, again.Salt) || fake.Verifier.Cmp(again.Verifier) != 0 {
+		t.Error("fake record isn't deterministic")
+	}
+	if len(fake.Salt) != len(enrolled.Salt) || fake.KDF != enrolled.KDF || fake.GroupID != enrolled.GroupID {
+		t.Error("fake record doesn't look like a real one")
+	}
+	if other := fc.Record("mallory", "device-1"); bytes.Equal(other.Salt, fake.Salt) {
+		t.Error("fake credentials of one identity share a salt")
+	}
+	if other := fc.Record("mallory\x00device-1", PrimaryCredential); bytes.Equal(other.Salt, fc.Record("mallory", "device-1").Salt) {
+		t.Error("fake records of names that run together share a salt")
+	}
+	if err := store.Enroll(ctx, fake); err == nil {
+		t.Error("enrolled fake record")
+	}
+
+	// Even someone who somehow knows the fake verifier's x can't get in.
+	x := KDFRFC5054(fake.Salt, fake.Identity, "guess")
+	v, err := NewClientStd(fake.Group(), x).Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Verifier = v
+	client := NewClientStd(fake.Group(), x)
+	server := NewServerFromRecord(fake)
+	if server == nil {
+		t.Fatal("failed to create server for fake record")
+	}
+	if err := server.SetOthersPublic(client.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetOthersPublic(server.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Key(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Key(); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := server.M(fake.Salt, fake.Identity)
+	if !client.GoodServerProof(fake.Salt, fake.Identity, m) {
+		t.Fatal("bad server proof")
+	}
+	proof, _ := client.ClientProof()
+	if server.GoodClientProof(proof) {
+		t.Error("fake server accepted client proof")
+	}
+}
+
+func TestFakeGroupWeights(t *testing.T) {
+	fc, err := NewFakeChallenger(bytes.Repeat([]byte{7}, MinFakeSecretSize), RFC5054Group2048, KDFParams{}, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.SetGroupWeights(map[int]int{RFC5054Group2048: 3, RFC5054Group3072: 1, RFC5054Group4096: 0}); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[int]int{}
+	for i := 0; i < 400; i++ {
+		identity := fmt.Sprintf("user%d", i)
+		rec := fc.Record(identity, PrimaryCredential)
+		if again := fc.Record(identity, PrimaryCredential); again.GroupID != rec.GroupID {
+			t.Fatal("fake record's group isn't deterministic")
+		}
+		counts[rec.GroupID]++
+	}
+	// Expect 300 and 100; these bounds are many standard deviations wide.
+	if counts[RFC5054Group2048] < 240 || counts[RFC5054Group3072] < 50 || counts[RFC5054Group4096] != 0 {
+		t.Errorf("fake groups %v don't follow the weights", counts)
+	}
+
+	if err := fc.SetGroupWeights(map[int]int{12345: 1}); err == nil {
+		t.Error("accepted unknown group")
+	}
+	if err := fc.SetGroupWeights(map[int]int{RFC5054Group2048: 0}); err == nil {
+		t.Error("accepted weights that are all zero")
+	}
+	if err := fc.SetGroupWeights(map[int]int{RFC5054Group2048: -1, RFC5054Group3072: 2}); err == nil {
+		t.Error("accepted a negative weight")
+	}
+}
+
+func TestNewFakeChallengerBadArgs(t *testing.T) {
+	if _, err := NewFakeChallenger(make([]byte, MinFakeSecretSize-1), RFC5054Group2048, KDFParams{}, 16); err == nil {
+		t.Error("accepted short secret")
+	}
+	if _, err := NewFakeChallenger(make([]byte, MinFakeSecretSize), 12345, KDFParams{}, 16); err == nil {
+		t.Error("accepted unknown group")
+	}
+	if _, err := NewFakeChallenger(make([]byte, MinFakeSecretSize), RFC5054Group2048, KDFParams{}, MaxSaltSize+1); err == nil {
+		t.Error("accepted salt longer than MaxSaltSize")
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,145 @@
+package srp
+
+import (
+	"math/big"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Every handshake computes g^a or g^b, and a client also computes g^x, always
+with the same g and N. So each of the KnownGroups has a table of powers of g
+that turns those exponentiations into a few dozen multiplications.
+
+The table has a row for each w-bit digit of an exponent, and row i holds
+
+	g^(d * 2^(w*i)) mod N, for d = 1 .. 2^w - 1
+
+so g^e is the product of one entry from each row, picked by e's digits, and
+needs no squarings at all. The table covers exponents as long as the group's
+ephemeral secrets (ExponentSize, or MinExponentSize if that is more); longer
+ones, and exponentiations in groups without a table, use big.Int's Exp.
+
+A group's table is built the first time it is used, which takes about as long
+as a few dozen exponentiations. w is the largest that fits in the memory set
+with SetFixedBaseMemory. Like Exp, the table doesn't run in constant time: which
+entries are used depends on the exponent.
+*/
+
+// DefaultFixedBaseMemory is the memory, in bytes, each group's table may use unless SetFixedBaseMemory is called.
+const DefaultFixedBaseMemory = 1 << 20
+
+// maxFixedBaseWindow bounds the digit size, where the table's build time stops paying for itself.
+const maxFixedBaseWindow = 8
+
+var fixedBaseMemory int64 = DefaultFixedBaseMemory
+
+/*
+SetFixedBaseMemory sets the memory, in bytes, each group's table of powers of
+its generator may use. Tables are built on first use, so this affects only
+those not yet used. Zero or less means no tables, and exponentiations of the
+generator use big.Int's Exp.
+*/
+func SetFixedBaseMemory(bytes int) {
+	atomic.StoreInt64(&fixedBaseMemory, int64(bytes))
+}
+
+// fixedBaseTable holds powers of a group's generator. It is shared by copies of the group.
+type fixedBaseTable struct {
+	once    sync.Once
+	window  int          // bits per digit; zero if there is no table
+	maxBits int          // of the longest exponent the table covers
+	rows    [][]*big.Int // rows[i][d-1] = g^(d * 2^(window*i)) mod N
+}
+
+func newFixedBaseTable() *fixedBaseTable {
+	return &fixedBaseTable{once: sync.Once{}, window: 0, maxBits: 0, rows: nil}
+}
+
+// expG sets z to g^e mod N and returns z, using the group's table when it can.
+func (g *Group) expG(z, e *big.Int) *big.Int {
+	if g.fixedBase == nil || e.Sign() <= 0 {
+		return z.Exp(g.g, e, g.n) // #nosec G105
+	}
+	t := g.fixedBase
+	t.once.Do(func() { t.build(g) })
+	if t.window == 0 || e.BitLen() > t.maxBits {
+		return z.Exp(g.g, e, g.n) // #nosec G105
+	}
+	return t.exp(z, e, g.n)
+}
+
+// build fills in the table for g, with the widest window that fits in fixedBaseMemory.
+func (t *fixedBaseTable) build(g *Group) {
+	if g.n == nil || g.n.Sign() <= 0 || g.g == nil {
+		return
+	}
+	t.maxBits = 8 * maxInt(g.ExponentSize, MinExponentSize)
+	entrySize := int64((g.n.BitLen() + 7) / 8)
+	budget := atomic.LoadInt64(&fixedBaseMemory)
+	for w := maxFixedBaseWindow; w > 0; w-- {
+		rows := (t.maxBits + w - 1) / w
+		if int64(rows)*int64((1<<w)-1)*entrySize <= budget {
+			t.window = w
+			break
+		}
+	}
+	if t.window == 0 {
+		return
+	}
+
+	rows := (t.maxBits + t.window - 1) / t.window
+	t.rows = make([][]*big.Int, rows)
+	base := new(big.Int).Mod(g.g, g.n) // g^(2^(window*i)) for the current row
+	for i := range t.rows {
+		row := make([]*big.Int, (1<<t.window)-1)
+		row[0] = new(big.Int).Set(base)
+		for d := 1; d < len(row); d++ {
+			row[d] = new(big.Int).Mul(row[d-1], base)
+			row[d].Mod(row[d], g.n)
+		}
+		t.rows[i] = row
+		// The next row's base is this one's raised to 2^window, one more than its last entry.
+		base = new(big.Int).Mul(row[len(row)-1], base)
+		base.Mod(base, g.n)
+	}
+}
+
+// exp sets z to g^e mod n from the table. e must be positive and no longer than t.maxBits.
+func (t *fixedBaseTable) exp(z, e, n *big.Int) *big.Int {
+	sc := getScratch()
+	defer putScratch(sc)
+	acc, prod := sc.acc.SetInt64(1), &sc.prod
+	bit := 0
+	for _, row := range t.rows {
+		d := 0
+		for j := 0; j < t.window; j++ {
+			d |= int(e.Bit(bit+j)) << j
+		}
+		bit += t.window
+		if d == 0 {
+			continue
+		}
+		prod.Mul(acc, row[d-1])
+		sc.mod(acc, prod, n)
+	}
+	return z.Set(acc)
+}
+
+// knownFixedBase returns the table of the known group with grp's generator and modulus, if there is one.
+func knownFixedBase(grp *Group) *fixedBaseTable {
+	if grp.g == nil || grp.n == nil {
+		return nil
+	}
+	for _, known := range KnownGroups {
+		if known.g != nil && known.n != nil && known.g.Cmp(grp.g) == 0 && known.n.Cmp(grp.n) == 0 {
+			return known.fixedBase
+		}
+	}
+	return nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,128 @@
+package srp
+
+import (
+	rand "crypto/rand"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// knownGroupIDs returns the IDs of KnownGroups in order.
+func knownGroupIDs() []int {
+	ids := make([]int, 0, len(KnownGroups))
+	for id := range KnownGroups {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// freshTable returns a copy of the known group id with a table not yet built.
+func freshTable(id int) *Group {
+	grp := *KnownGroups[id]
+	grp.fixedBase = newFixedBaseTable()
+	return &grp
+}
+
+func TestFixedBase(t *testing.T) {
+	defer SetFixedBaseMemory(DefaultFixedBaseMemory)
+	for _, memory := range []int{DefaultFixedBaseMemory, 64 << 10, 0} {
+		SetFixedBaseMemory(memory)
+		for _, id := range knownGroupIDs() {
+			if KnownGroups[id].fixedBase == nil {
+				continue // added by a test
+			}
+			grp := freshTable(id)
+			maxBits := 8 * maxInt(grp.ExponentSize, MinExponentSize)
+			exponents := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2)}
+			for _, bits := range []int{8, maxBits - 1, maxBits, maxBits + 1, 2 * maxBits} {
+				e, err := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, uint(bits)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				exponents = append(exponents, e, new(big.Int).Sub(new(big.Int).Lsh(bigOne, uint(bits)), bigOne))
+			}
+			for _, e := range exponents {
+				want := new(big.Int).Exp(grp.g, e, grp.n)
+				if got := grp.expG(new(big.Int), e); got.Cmp(want) != 0 {
+					t.Errorf("%s with %d bytes: g^%x is wrong", grp.Label, memory, e)
+				}
+			}
+			if memory == 0 && grp.fixedBase.rows != nil {
+				t.Errorf("%s has a table with no memory for one", grp.Label)
+			}
+			if memory == DefaultFixedBaseMemory && grp.fixedBase.window < 3 {
+				t.Errorf("%s has a window of only %d bits", grp.Label, grp.fixedBase.window)
+			}
+		}
+	}
+}
+
+func TestFixedBaseConcurrent(t *testing.T) {
+	grp := freshTable(RFC5054Group2048)
+	e := big.NewInt(0xdeadbeef)
+	want := new(big.Int).Exp(grp.g, e, grp.n)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := grp.expG(new(big.Int), e); got.Cmp(want) != 0 {
+				t.Error("wrong result while the table was being built")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFixedBaseUnmarshal(t *testing.T) {
+	data, err := KnownGroups[RFC5054Group3072].MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var grp Group
+	if err := grp.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if grp.fixedBase != KnownGroups[RFC5054Group3072].fixedBase {
+		t.Error("unmarshaled group doesn't share the known group's table")
+	}
+}
+
+func BenchmarkFixedBase(b *testing.B) {
+	for _, id := range knownGroupIDs() {
+		grp := KnownGroups[id]
+		if grp.fixedBase == nil {
+			continue
+		}
+		e := make([]byte, maxInt(grp.ExponentSize, MinExponentSize))
+		if _, err := rand.Read(e); err != nil {
+			b.Fatal(err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		z := new(big.Int)
+		b.Run(grp.Label+"/Exp", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				z.Exp(grp.g, exponent, grp.n)
+			}
+		})
+		b.Run(grp.Label+"/table", func(b *testing.B) {
+			grp.expG(z, exponent) // build the table
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				grp.expG(z, exponent)
+			}
+		})
+		b.Run(grp.Label+"/build", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				newFixedBaseTable().build(grp)
+			}
+		})
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
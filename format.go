@@ -0,0 +1,162 @@
+package srp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+/*
+An SRP holds x, v, its ephemeral secret, the premaster secret and the key, and
+printing one with the fmt verbs would print them all. So SRP and Group format
+themselves, with every verb, showing only what may be logged: the role, the group,
+the phase of the exchange and fingerprints of the public values. A fingerprint is
+the first 8 bytes of the SHA-256 of a padded value, in hex.
+
+The methods have value receivers so that an SRP is redacted whether it is
+printed by pointer or by value. Building with the srpdebug tag makes %+v print
+everything, for tests; never ship a build with it.
+*/
+
+// Phases of an exchange, as printed by SRP's String.
+const (
+	phaseNew          = "new"           // the other party's public value isn't set
+	phaseExchanged    = "exchanged"     // it is, but the key hasn't been computed
+	phaseKeyed        = "keyed"         // the key has been computed
+	phaseServerProved = "server proved" // the server has proved itself to the client
+	phaseClientProved = "client proved" // the client has proved itself to the server
+	phaseFailed       = "failed"        // a bad value was given, and the exchange can't go on
+)
+
+// String returns a description of s without any of its secrets.
+//
+//nolint:gocritic // a value receiver, so that SRP values are redacted too
+func (s SRP) String() string {
+	return fmt.Sprintf("SRP(%s, %s, %s, A=%s, B=%s)",
+		s.role(), s.groupLabel(), s.phase(), s.fingerprint(s.ephemeralPublicA), s.fingerprint(s.ephemeralPublicB))
+}
+
+// GoString returns a Go-like description of s without any of its secrets, for %#v.
+//
+//nolint:gocritic // a value receiver, so that SRP values are redacted too
+func (s SRP) GoString() string {
+	return fmt.Sprintf("srp.SRP{Role:%q, Group:%q, Phase:%q, A:%q, B:%q}",
+		s.role(), s.groupLabel(), s.phase(), s.fingerprint(s.ephemeralPublicA), s.fingerprint(s.ephemeralPublicB))
+}
+
+// Format implements fmt.Formatter, so that no verb prints s's secrets.
+//
+//nolint:gocritic // a value receiver, so that SRP values are redacted too
+func (s SRP) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('+') {
+		if dump, ok := s.debugDump(); ok {
+			_, _ = io.WriteString(f, dump)
+			return
+		}
+	}
+	formatRedacted(f, verb, "srp.SRP", s.String(), s.GoString())
+}
+
+func (s *SRP) role() string {
+	if s.isServer {
+		return "server"
+	}
+	return "client"
+}
+
+func (s *SRP) groupLabel() string {
+	if s.group == nil {
+		return "no group"
+	}
+	return s.group.Label
+}
+
+func (s *SRP) phase() string {
+	switch {
+	case s.badState:
+		return phaseFailed
+	case s.isClientProved:
+		return phaseClientProved
+	case s.isServerProved:
+		return phaseServerProved
+	case s.key != nil:
+		return phaseKeyed
+	case s.isServer && !isUnset(s.ephemeralPublicA), !s.isServer && !isUnset(s.ephemeralPublicB):
+		return phaseExchanged
+	default:
+		return phaseNew
+	}
+}
+
+// fingerprint returns a short, printable digest of the public value x.
+func (s *SRP) fingerprint(x *big.Int) string {
+	if isUnset(x) {
+		return "none"
+	}
+	var b []byte
+	if s.group != nil && s.group.n != nil && s.group.n.Sign() > 0 {
+		b = s.group.PaddedBytes(x)
+	} else {
+		b = x.Bytes()
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+func isUnset(x *big.Int) bool {
+	return x == nil || x.Sign() == 0
+}
+
+// String returns the group's label and size.
+//
+//nolint:gocritic // a value receiver, so that Group values are printed the same way
+func (g Group) String() string {
+	return fmt.Sprintf("%s (%d bits)", g.Label, g.bits())
+}
+
+// GoString returns a Go-like description of the group, for %#v.
+//
+//nolint:gocritic // a value receiver, so that Group values are printed the same way
+func (g Group) GoString() string {
+	return fmt.Sprintf("srp.Group{Label:%q, Bits:%d, ExponentSize:%d}", g.Label, g.bits(), g.ExponentSize)
+}
+
+// Format implements fmt.Formatter. It prints the same thing as String or GoString for every verb.
+//
+//nolint:gocritic // a value receiver, so that Group values are printed the same way
+func (g Group) Format(f fmt.State, verb rune) {
+	formatRedacted(f, verb, "srp.Group", g.String(), g.GoString())
+}
+
+func (g *Group) bits() int {
+	if g.n == nil {
+		return 0
+	}
+	return g.n.BitLen()
+}
+
+// formatRedacted writes str for %v and %s, gostr for %#v, str quoted for %q, and
+// a fmt-style complaint for any other verb, rather than the value's fields.
+func formatRedacted(f fmt.State, verb rune, typ, str, gostr string) {
+	switch verb {
+	case 'v':
+		if f.Flag('#') {
+			_, _ = io.WriteString(f, gostr)
+			return
+		}
+		_, _ = io.WriteString(f, str)
+	case 's':
+		_, _ = io.WriteString(f, str)
+	case 'q':
+		_, _ = fmt.Fprintf(f, "%q", str)
+	default:
+		_, _ = fmt.Fprintf(f, "%%!%c(%s=%s)", verb, typ, str)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,19 @@
+//go:build srpdebug
+// +build srpdebug
+
+package srp
+
+import "fmt"
+
+// debugDump returns everything in s, secrets included, for %+v in builds with the srpdebug tag.
+func (s *SRP) debugDump() (string, bool) {
+	return fmt.Sprintf("%s{ephemeralPrivate:%x ephemeralPublicA:%x ephemeralPublicB:%x x:%x v:%x u:%x k:%x "+
+		"premasterKey:%x key:%x m:%x cProof:%x credChanged:%t isFake:%t hashName:%s stdPadding:%t}",
+		s.String(), s.ephemeralPrivate, s.ephemeralPublicA, s.ephemeralPublicB, s.x, s.v, s.u, s.k,
+		s.premasterKey, s.key, s.m, s.cProof, s.credChanged, s.isFake, s.hashName, s.stdPadding), true
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,14 @@
+//go:build !srpdebug
+// +build !srpdebug
+
+package srp
+
+// debugDump would return everything in s. Without the srpdebug tag it returns false.
+func (s *SRP) debugDump() (string, bool) {
+	return "", false
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,34 @@
+//go:build go1.21
+// +build go1.21
+
+package srp
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer, so that logging an SRP logs none of its secrets.
+//
+//nolint:gocritic // a value receiver, so that SRP values are redacted too
+func (s SRP) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("role", s.role()),
+		slog.String("group", s.groupLabel()),
+		slog.String("phase", s.phase()),
+		slog.String("A", s.fingerprint(s.ephemeralPublicA)),
+		slog.String("B", s.fingerprint(s.ephemeralPublicB)),
+	)
+}
+
+// LogValue implements slog.LogValuer.
+//
+//nolint:gocritic // a value receiver, so that Group values are logged the same way
+func (g Group) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("label", g.Label),
+		slog.Int("bits", g.bits()),
+	)
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,34 @@
+//go:build go1.21
+// +build go1.21
+
+package srp
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogValue(t *testing.T) {
+	grp := KnownGroups[RFC5054Group2048]
+	x := NumberFromString("0x 1234567890abcdef1234567890abcdef")
+	client := NewClientStd(grp, x)
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("handshake", "srp", client, "group", grp)
+	got := buf.String()
+	for _, want := range []string{"srp.role=client", "srp.group=5054A2048", "srp.phase=new", "srp.B=none", "group.bits=2048"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log %q doesn't contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, x.Text(16)) || strings.Contains(got, client.ephemeralPrivate.Text(16)) {
+		t.Errorf("log %q contains a secret", got)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,71 @@
+package srp
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestFormatRedacts(t *testing.T) {
+	grp := KnownGroups[RFC5054Group2048]
+	x := NumberFromString("0x 1234567890abcdef1234567890abcdef")
+	client, server := keyedPair(t, std, grp, x, nil)
+
+	var secrets []string
+	for _, s := range []*SRP{client, server} {
+		for _, n := range []*big.Int{s.x, s.v, s.ephemeralPrivate, s.premasterKey} {
+			if n != nil && n.Sign() != 0 {
+				secrets = append(secrets, n.Text(16), n.Text(10), fmt.Sprintf("%X", n))
+			}
+		}
+		secrets = append(secrets, fmt.Sprintf("%x", s.key), fmt.Sprintf("%v", s.key))
+	}
+
+	_, debug := client.debugDump()
+	for _, s := range []*SRP{client, server} {
+		for _, f := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%d", "%10v"} {
+			if debug && f == "%+v" {
+				continue
+			}
+			for _, out := range []string{fmt.Sprintf(f, s), fmt.Sprintf(f, *s), fmt.Sprintf(f, []*SRP{s})} {
+				for _, secret := range secrets {
+					if strings.Contains(out, secret) {
+						t.Errorf("%s of %s prints secret %q", f, s, secret)
+					}
+				}
+			}
+		}
+	}
+
+	if got, want := fmt.Sprint(server), "SRP(server, 5054A2048, keyed, A="; !strings.HasPrefix(got, want) {
+		t.Errorf("got %q, want prefix %q", got, want)
+	}
+	if got := fmt.Sprint(NewClientStd(grp, x)); !strings.Contains(got, "new") || !strings.HasSuffix(got, "B=none)") {
+		t.Errorf("new client printed as %q", got)
+	}
+	if got, want := fmt.Sprintf("%#v", client), `srp.SRP{Role:"client", Group:"5054A2048", Phase:"keyed", A:"`; !strings.HasPrefix(got, want) {
+		t.Errorf("got %q, want prefix %q", got, want)
+	}
+	if fmt.Sprint(client) == fmt.Sprint(NewClientStd(grp, x)) {
+		t.Error("fingerprints of different A are the same")
+	}
+}
+
+func TestFormatGroup(t *testing.T) {
+	grp := KnownGroups[RFC5054Group2048]
+	for _, f := range []string{"%v", "%+v", "%s"} {
+		if got, want := fmt.Sprintf(f, grp), "5054A2048 (2048 bits)"; got != want {
+			t.Errorf("%s: got %q, want %q", f, got, want)
+		}
+	}
+	want := fmt.Sprintf(`srp.Group{Label:"5054A2048", Bits:2048, ExponentSize:%d}`, grp.ExponentSize)
+	if got := fmt.Sprintf("%#v", *grp); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -3,6 +3,8 @@ module github.com/1Password/srp
 go 1.15
 
 require (
+	github.com/lib/pq v1.10.6
+	github.com/mattn/go-sqlite3 v1.14.15
 	github.com/pkg/errors v0.9.1
 	golang.org/x/text v0.3.7
 	golang.org/x/tools v0.1.10
@@ -15,7 +15,8 @@ import (
 type Group struct {
 	g, n, k      *big.Int // generator, modulus, k = H(n, PAD(g))
 	Label        string
-	ExponentSize int // RFC 3526 §8
+	ExponentSize int             // RFC 3526 §8
+	fixedBase    *fixedBaseTable // powers of g, for the known groups
 }
 
 // N returns the modulus of the the group.
@@ -36,9 +37,22 @@ func (g *Group) Reduce(x *big.Int) *big.Int {
 // IsZero returns whether x is 0 modulo group modulus.
 func (g *Group) IsZero(x *big.Int) bool {
 	// big.Ints have a sign of -1, 0, or 1. 0 is what we are looking for
+	if g.inRange(x) {
+		return x.Sign() == 0
+	}
 	return (&big.Int{}).Mod(x, g.n).Sign() == 0
 }
 
+// inRange reports whether 0 <= x < N, in which case x is already reduced.
+func (g *Group) inRange(x *big.Int) bool {
+	return x.Sign() >= 0 && x.Cmp(g.n) < 0
+}
+
+// byteLen returns the length of N in bytes.
+func (g *Group) byteLen() int {
+	return (g.n.BitLen() + 7) / 8
+}
+
 // LittleK returns H(N, PAD(g)), the multiplier used SRP computations.
 func (g *Group) LittleK(hashName string) *big.Int {
 	if g.k != nil {
@@ -79,14 +93,15 @@ func (g *Group) computeK(h hash.Hash) (*big.Int, error) {
 
 // PaddedBytes returns x (mod N), padded to be the same byte length as N.
 func (g *Group) PaddedBytes(x *big.Int) []byte {
-	reducedX := (&big.Int{}).Mod(x, g.n)
-
-	// Get N and x as byte arrays, with x zero padded to be same size as N
-	NBytes := g.n.Bytes()
-	xBytes := make([]byte, len(NBytes))
-	xBytes = reducedX.FillBytes(xBytes)
+	return g.fillPadded(make([]byte, g.byteLen()), x)
+}
 
-	return xBytes
+// fillPadded is PaddedBytes into dst, which must be as long as N.
+func (g *Group) fillPadded(dst []byte, x *big.Int) []byte {
+	if !g.inRange(x) {
+		x = (&big.Int{}).Mod(x, g.n)
+	}
+	return x.FillBytes(dst)
 }
 
 // MarshalBinary returns a binary gob with the complete state of the Group object.
@@ -126,6 +141,7 @@ func (g *Group) UnmarshalBinary(data []byte) (err error) {
 			return fmt.Errorf("decoding failure: %w", err)
 		}
 	}
+	g.fixedBase = knownFixedBase(g)
 
 	return nil
 }
@@ -170,6 +186,7 @@ func init() {
 		k:            nil,
 		Label:        "5054A2048",
 		ExponentSize: 27,
+		fixedBase:    newFixedBaseTable(),
 	}
 
 	g3072n := NumberFromString("0xFFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
@@ -194,6 +211,7 @@ func init() {
 		k:            nil,
 		Label:        "5054A3072",
 		ExponentSize: 32,
+		fixedBase:    newFixedBaseTable(),
 	}
 
 	// RFC 3526 id 16
@@ -222,6 +240,7 @@ func init() {
 		k:            nil,
 		Label:        "5054A4096",
 		ExponentSize: 38,
+		fixedBase:    newFixedBaseTable(),
 	}
 
 	// RFC 3526 group id 17
@@ -259,6 +278,7 @@ func init() {
 		k:            nil,
 		Label:        "5054A6144",
 		ExponentSize: 43,
+		fixedBase:    newFixedBaseTable(),
 	}
 
 	// RFC 3526 group id 18
@@ -305,6 +325,7 @@ func init() {
 		k:            nil,
 		Label:        "5054A8192",
 		ExponentSize: 48,
+		fixedBase:    newFixedBaseTable(),
 	}
 
 	KnownGroups[RFC5054Group2048] = g2048
@@ -0,0 +1,35 @@
+package srp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// hkdfExtract is HKDF-Extract from RFC 5869 with SHA-256.
+func hkdfExtract(salt, ikm []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+// hkdfExpand is HKDF-Expand from RFC 5869 with SHA-256.
+// length must be at most 255 * sha256.Size.
+// Writes to a hash.Hash never return an error, so none are checked.
+func hkdfExpand(prk, info []byte, length int) []byte {
+	out := make([]byte, 0, length+sha256.Size)
+	var prev []byte
+	for counter := byte(1); len(out) < length; counter++ {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(prev)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+		prev = mac.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:length]
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
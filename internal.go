@@ -1,11 +1,13 @@
 package srp
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
 	rand "crypto/rand"
-	"encoding/hex"
+	"crypto/sha256"
 	"fmt"
 	"math/big"
-	"strings"
 )
 
 /*
@@ -23,15 +25,18 @@ methods in here.
 // a minimum of 32 bytes.
 func (s *SRP) generateMySecret() *big.Int {
 	eSize := maxInt(s.group.ExponentSize, MinExponentSize)
-	bytes := make([]byte, eSize)
+	sc := getScratch()
+	defer putScratch(sc)
+	bytes := sc.bytes(eSize)
 	_, err := rand.Read(bytes)
 	if err != nil {
 		// If we can't get random bytes from the system, then we have no business doing anything crypto related.
 		panic(fmt.Sprintf("Failed to get random bytes: %v", err))
 	}
-	ephemeralPrivate := &big.Int{}
-	ephemeralPrivate.SetBytes(bytes)
-	s.ephemeralPrivate = ephemeralPrivate
+	if s.ephemeralPrivate == nil {
+		s.ephemeralPrivate = &big.Int{}
+	}
+	s.ephemeralPrivate.SetBytes(bytes)
 	return s.ephemeralPrivate
 }
 
@@ -69,20 +74,25 @@ func (s *SRP) makeLittleKNonStd() (*big.Int, error) {
 	// We will remake k, even if already created, as server needs to
 	// remake it after manually setting k
 
-	h := Hash.NewWith(s.hashName)
+	sc := getScratch()
+	defer putScratch(sc)
+	h := sc.hash(s.hashName)
 	if h == nil {
 		return nil, fmt.Errorf("failed to get hash function")
 	}
-	_, err := h.Write(s.group.n.Bytes())
+	buf := sc.bytes(s.group.byteLen())
+	_, err := h.Write(unpaddedBytes(buf, s.group.n))
 	if err != nil {
 		return nil, fmt.Errorf("failed to write N to hasher: %w", err)
 	}
-	_, err = h.Write(s.group.g.Bytes())
+	_, err = h.Write(unpaddedBytes(buf, s.group.g))
 	if err != nil {
 		return nil, fmt.Errorf("failed to write g to hasher: %w", err)
 	}
-	k := &big.Int{}
-	s.k = k.SetBytes(h.Sum(nil))
+	if s.k == nil {
+		s.k = &big.Int{}
+	}
+	s.k.SetBytes(h.Sum(sc.sum[:0]))
 
 	return s.k, nil
 }
@@ -99,16 +109,15 @@ func (s *SRP) makeA() (*big.Int, error) {
 		s.ephemeralPrivate = s.generateMySecret()
 	}
 
-	s.ephemeralPublicA = &big.Int{}
-	result := s.ephemeralPublicA.Exp(s.group.g, s.ephemeralPrivate, s.group.n)
+	if s.ephemeralPublicA == nil {
+		s.ephemeralPublicA = &big.Int{}
+	}
+	result := s.group.expG(s.ephemeralPublicA, s.ephemeralPrivate)
 	return result, nil
 }
 
 // makeB calculates B and returns it.
 func (s *SRP) makeB() (*big.Int, error) {
-	term1 := &big.Int{}
-	term2 := &big.Int{}
-
 	// Absolute Prerequisites: Group, isServer, v
 	if s.group == nil {
 		return nil, fmt.Errorf("group not set")
@@ -124,6 +133,10 @@ func (s *SRP) makeB() (*big.Int, error) {
 		return nil, fmt.Errorf("something is wrong if modulus is zero")
 	}
 
+	sc := getScratch()
+	defer putScratch(sc)
+	term1, term2 := &sc.t1, &sc.t2
+
 	// Generatable prerequisites: k, b if needed
 	if s.group.IsZero(s.k) {
 		var err error
@@ -132,16 +145,24 @@ func (s *SRP) makeB() (*big.Int, error) {
 		}
 	}
 	if s.group.IsZero(s.ephemeralPrivate) {
-		s.ephemeralPrivate = s.generateMySecret()
+		// A pair from an EphemeralPool saves computing g^b now.
+		if b, gb := takePooledEphemeral(s.group); b != nil {
+			s.ephemeralPrivate = b
+			term2.Set(gb)
+		} else {
+			s.ephemeralPrivate = s.generateMySecret()
+		}
 	}
 
 	// B = kv + g^b  (term1 is kv, term2 is g^b)
 	// We also do some modular reduction on some of our intermediate values
-	term2.Exp(s.group.g, s.ephemeralPrivate, s.group.n) // #nosec G105
+	if term2.Sign() == 0 {
+		s.group.expG(term2, s.ephemeralPrivate)
+	}
 	term1.Mul(s.k, s.v)
-	term1 = s.group.Reduce(term1)
+	sc.mod(term1, term1, s.group.n)
 	s.ephemeralPublicB.Add(term1, term2)
-	s.ephemeralPublicB = s.group.Reduce(s.ephemeralPublicB)
+	sc.mod(s.ephemeralPublicB, s.ephemeralPublicB, s.group.n)
 
 	return s.ephemeralPublicB, nil
 }
@@ -169,7 +190,7 @@ func (s *SRP) makeVerifier() (*big.Int, error) {
 		return nil, fmt.Errorf("x must be known to calculate v")
 	}
 
-	result := s.v.Exp(s.group.g, s.x, s.group.n)
+	result := s.group.expG(s.v, s.x)
 
 	return result, nil
 }
@@ -195,21 +216,24 @@ func (s *SRP) calculateUNonStd() (*big.Int, error) {
 		return nil, fmt.Errorf("both A and B must be known to calculate u")
 	}
 
-	h := Hash.NewWith(s.hashName)
+	sc := getScratch()
+	defer putScratch(sc)
+	h := sc.hash(s.hashName)
 	if h == nil {
 		return nil, fmt.Errorf("failed to set up hash function")
 	}
+	trimmedHexPublicAB := appendTrimmedHex(sc.buffer(4*s.group.byteLen()), s.ephemeralPublicA)
+	trimmedHexPublicAB = appendTrimmedHex(trimmedHexPublicAB, s.ephemeralPublicB)
 
-	trimmedHexPublicA := serverStyleHexFromBigInt(s.ephemeralPublicA)
-	trimmedHexPublicB := serverStyleHexFromBigInt(s.ephemeralPublicB)
-
-	_, err := h.Write([]byte(fmt.Sprintf("%s%s", trimmedHexPublicA, trimmedHexPublicB)))
+	_, err := h.Write(trimmedHexPublicAB)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write to hasher: %w", err)
 	}
 
-	u := &big.Int{}
-	s.u = u.SetBytes(h.Sum(nil))
+	if s.u == nil {
+		s.u = &big.Int{}
+	}
+	s.u.SetBytes(h.Sum(sc.sum[:0]))
 	if s.group.IsZero(s.u) {
 		return nil, fmt.Errorf("u == 0, which is a bad thing")
 	}
@@ -225,11 +249,14 @@ func (s *SRP) calculateUStd() (*big.Int, error) {
 
 	// A and B will be big-endian byte arrays padded to byte length of N
 	grp := s.group
-	lenN := len(grp.N().Bytes())
-	A := grp.PaddedBytes(s.ephemeralPublicA)
-	B := grp.PaddedBytes(s.ephemeralPublicB)
-
-	h := Hash.NewWith(s.hashName)
+	lenN := grp.byteLen()
+	sc := getScratch()
+	defer putScratch(sc)
+	AB := sc.bytes(2 * lenN)
+	A := grp.fillPadded(AB[:lenN], s.ephemeralPublicA)
+	B := grp.fillPadded(AB[lenN:], s.ephemeralPublicB)
+
+	h := sc.hash(s.hashName)
 	if h == nil {
 		return nil, fmt.Errorf("failed to set up hash function")
 	}
@@ -243,8 +270,10 @@ func (s *SRP) calculateUStd() (*big.Int, error) {
 	if err != nil || b != lenN {
 		return nil, fmt.Errorf("failed to write B to hasher: %w", err)
 	}
-	u := &big.Int{}
-	s.u = u.SetBytes(h.Sum(nil))
+	if s.u == nil {
+		s.u = &big.Int{}
+	}
+	s.u.SetBytes(h.Sum(sc.sum[:0]))
 	if s.group.IsZero(s.u) {
 		return nil, fmt.Errorf("u == 0, which is a bad thing")
 	}
@@ -254,15 +283,56 @@ func (s *SRP) calculateUStd() (*big.Int, error) {
 // Convert a bigInt to a lowercase hex string with leading "0"s removed.
 // We do this explicitly instead of as an artifact of fmt.Sprintf.
 func serverStyleHexFromBigInt(bn *big.Int) string {
-	// Don't worry. The compiler will build things the same even if we didn't create
-	// all of the intermediate variables below. And this better communicates all these
-	// things we are doing to construct these strings
-	b := bn.Bytes()
-	h := hex.EncodeToString(b)
-	l := strings.ToLower(h)
-	res := strings.TrimLeft(l, "0")
-
-	return res
+	return string(appendTrimmedHex(nil, bn))
+}
+
+// sessionAEAD returns AES-256-GCM keyed with HMAC(K, label).
+// Each distinct use of the session key gets its own label and so its own key.
+func (s *SRP) sessionAEAD(label string) (cipher.AEAD, error) {
+	if s.key == nil {
+		return nil, fmt.Errorf("no session key")
+	}
+	mac := hmac.New(sha256.New, s.key)
+	if _, err := mac.Write([]byte(label)); err != nil {
+		return nil, fmt.Errorf("failed to derive key for %s: %w", label, err)
+	}
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealWithSessionKey encrypts plaintext with the session AEAD for label.
+// The label and identity are associated data. The result is nonce | ciphertext.
+func (s *SRP) sealWithSessionKey(label, identity string, plaintext []byte) ([]byte, error) {
+	aead, err := s.sessionAEAD(label)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// If we can't get random bytes from the system, then we have no business doing anything crypto related.
+		panic(fmt.Sprintf("Failed to get random bytes: %v", err))
+	}
+	return aead.Seal(nonce, nonce, plaintext, sessionSealAD(label, identity)), nil
+}
+
+// openWithSessionKey reverses sealWithSessionKey.
+func (s *SRP) openWithSessionKey(label, identity string, sealed []byte) ([]byte, error) {
+	aead, err := s.sessionAEAD(label)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("sealed message too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, sessionSealAD(label, identity))
+}
+
+func sessionSealAD(label, identity string) []byte {
+	return []byte(label + "\x00" + identity)
 }
 
 /**
@@ -0,0 +1,319 @@
+package srp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/*
+A wrong client proof is an online guess at a password, and GoodClientProof
+can't tell a typo from the thousandth guess. An AttemptLimiter keeps count.
+A server calls Check before starting a handshake, before it looks up a
+record or does any exponentiation, and Attempt before it checks the client's
+proof, as any number of handshakes may have been started before the first of
+them failed. It then calls Failed or Succeeded, as GoodClientProof says.
+
+Attempt reserves a slot, which counts as a failure until Failed or Succeeded
+releases it, so that proofs checked at once can't all get in before the first
+of them is counted. Every Attempt that returns no wait must be followed by
+exactly one Failed or Succeeded.
+
+Only wrong proofs count. The client proves itself first, so a client that
+abandons a handshake before sending its proof has learned nothing about the
+password and has no guess to count.
+
+Failures are counted both per identity, against guessing one password from many
+places, and per source (typically an IP address), against one place guessing
+many passwords. A success clears the identity's failures but not the source's,
+or an attacker with an account of their own could clear their source's count
+between guesses.
+*/
+
+// AttemptLimiter decides when handshakes may be attempted.
+type AttemptLimiter interface {
+	// Check returns how long the caller must wait before starting a handshake
+	// for identity from source. It reserves nothing.
+	Check(ctx context.Context, identity, source string) (time.Duration, error)
+	// Attempt returns how long the caller must wait before checking a client's
+	// proof for identity from source. If it returns no wait, it has reserved a
+	// slot for the proof.
+	Attempt(ctx context.Context, identity, source string) (time.Duration, error)
+	// Failed records a wrong client proof for identity from source, releasing
+	// its slot.
+	Failed(ctx context.Context, identity, source string) error
+	// Succeeded records a good client proof for identity from source, releasing
+	// its slot.
+	Succeeded(ctx context.Context, identity, source string) error
+}
+
+/*
+LimitPolicy describes how failures delay further attempts. After
+FreeAttempts failures each further failure doubles the wait before the next
+attempt, starting at BaseDelay and capped at MaxDelay. Once there have been
+LockoutThreshold failures the wait is LockoutDuration instead. Failures are
+forgotten after ResetAfter without any.
+
+The zero LimitPolicy uses the defaults, except that a zero LockoutThreshold
+means there is no lockout.
+*/
+type LimitPolicy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	ResetAfter       time.Duration
+}
+
+// Defaults for LimitPolicy.
+const (
+	DefaultFreeAttempts    = 3
+	DefaultBaseDelay       = time.Second
+	DefaultMaxDelay        = 15 * time.Minute
+	DefaultLockoutDuration = time.Hour
+	DefaultResetAfter      = 24 * time.Hour
+)
+
+func (p LimitPolicy) withDefaults() LimitPolicy {
+	if p.FreeAttempts <= 0 {
+		p.FreeAttempts = DefaultFreeAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultMaxDelay
+	}
+	if p.LockoutDuration <= 0 {
+		p.LockoutDuration = DefaultLockoutDuration
+	}
+	if p.ResetAfter <= 0 {
+		p.ResetAfter = DefaultResetAfter
+	}
+	return p
+}
+
+// delay returns the wait after failures failures.
+func (p LimitPolicy) delay(failures int) time.Duration {
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		d *= 2
+		if d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// LimiterConfig configures a MemoryLimiter. The zero LimiterConfig uses the defaults.
+type LimiterConfig struct {
+	Identity LimitPolicy // per identity
+	Source   LimitPolicy // per source
+
+	// Now returns the current time. Nil means time.Now. It is there for tests.
+	Now func() time.Time
+}
+
+// MemoryLimiter is an AttemptLimiter that keeps counts in memory,
+// so a server with several instances gets a separate count in each.
+// An empty source isn't counted.
+type MemoryLimiter struct {
+	mu         sync.Mutex
+	identity   LimitPolicy
+	source     LimitPolicy
+	now        func() time.Time
+	identities map[string]*attempts
+	sources    map[string]*attempts
+	sweepAt    int // sweep forgotten counts once there are this many
+}
+
+type attempts struct {
+	failures int
+	pending  int       // slots reserved by Attempt and not yet released
+	last     time.Time // of the last failure
+	reserved time.Time // of the last reservation
+}
+
+// stale reports whether a has been forgotten under p: there has been neither a
+// failure nor a reservation for ResetAfter. Reservations that were never
+// released go with it.
+func (a *attempts) stale(now time.Time, p LimitPolicy) bool {
+	return now.Sub(a.last) >= p.ResetAfter && now.Sub(a.reserved) >= p.ResetAfter
+}
+
+// release gives back a slot reserved by Attempt, if there is one.
+func (a *attempts) release() {
+	if a.pending > 0 {
+		a.pending--
+	}
+}
+
+var _ AttemptLimiter = &MemoryLimiter{} //nolint:exhaustruct
+
+// NewMemoryLimiter returns a MemoryLimiter. cfg may be nil.
+func NewMemoryLimiter(cfg *LimiterConfig) *MemoryLimiter {
+	var c LimiterConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return &MemoryLimiter{
+		identity:   c.Identity.withDefaults(),
+		source:     c.Source.withDefaults(),
+		now:        c.Now,
+		identities: make(map[string]*attempts),
+		sources:    make(map[string]*attempts),
+		sweepAt:    1024,
+	}
+}
+
+// Check implements AttemptLimiter.
+func (ml *MemoryLimiter) Check(_ context.Context, identity, source string) (time.Duration, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	return ml.waitFor(ml.now(), identity, source), nil
+}
+
+// Attempt implements AttemptLimiter.
+func (ml *MemoryLimiter) Attempt(_ context.Context, identity, source string) (time.Duration, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	now := ml.now()
+	if wait := ml.waitFor(now, identity, source); wait > 0 {
+		return wait, nil
+	}
+	ml.sweep(now)
+	ml.reserve(now, ml.identities, identity)
+	if source != "" {
+		ml.reserve(now, ml.sources, source)
+	}
+	return 0, nil
+}
+
+// Failed implements AttemptLimiter.
+func (ml *MemoryLimiter) Failed(_ context.Context, identity, source string) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	now := ml.now()
+	ml.sweep(now)
+	ml.fail(now, ml.identities, identity, ml.identity)
+	if source != "" {
+		ml.fail(now, ml.sources, source, ml.source)
+	}
+	return nil
+}
+
+// Succeeded implements AttemptLimiter.
+func (ml *MemoryLimiter) Succeeded(_ context.Context, identity, source string) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if a, ok := ml.identities[identity]; ok {
+		// Other proofs for the identity may still be being checked.
+		if a.pending > 1 {
+			a.failures, a.pending = 0, a.pending-1
+		} else {
+			delete(ml.identities, identity)
+		}
+	}
+	if a, ok := ml.sources[source]; ok {
+		a.release()
+	}
+	return nil
+}
+
+// waitFor returns the longer of the waits for identity and source. ml.mu must be held.
+func (ml *MemoryLimiter) waitFor(now time.Time, identity, source string) time.Duration {
+	wait := ml.wait(now, ml.identities, identity, ml.identity)
+	if source != "" {
+		if w := ml.wait(now, ml.sources, source, ml.source); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// wait returns the wait for key, counting each reserved slot as a failure now.
+func (ml *MemoryLimiter) wait(now time.Time, counts map[string]*attempts, key string, p LimitPolicy) time.Duration {
+	a, ok := counts[key]
+	if !ok {
+		return 0
+	}
+	if a.stale(now, p) {
+		delete(counts, key)
+		return 0
+	}
+	failures, from := a.failures, a.last
+	if now.Sub(a.last) >= p.ResetAfter {
+		failures = 0
+	}
+	if a.pending > 0 {
+		failures += a.pending
+		from = now
+	}
+	if wait := from.Add(p.delay(failures)).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (ml *MemoryLimiter) reserve(now time.Time, counts map[string]*attempts, key string) {
+	a, ok := counts[key]
+	if !ok {
+		a = &attempts{}
+		counts[key] = a
+	}
+	a.pending++
+	a.reserved = now
+}
+
+func (ml *MemoryLimiter) fail(now time.Time, counts map[string]*attempts, key string, p LimitPolicy) {
+	a, ok := counts[key]
+	if !ok || a.stale(now, p) {
+		a = &attempts{}
+		counts[key] = a
+	} else if now.Sub(a.last) >= p.ResetAfter {
+		a.failures = 0
+	}
+	a.release()
+	a.failures++
+	a.last = now
+}
+
+// sweep forgets counts that have been reset. ml.mu must be held.
+func (ml *MemoryLimiter) sweep(now time.Time) {
+	if len(ml.identities)+len(ml.sources) < ml.sweepAt {
+		return
+	}
+	for k, a := range ml.identities {
+		if a.stale(now, ml.identity) {
+			delete(ml.identities, k)
+		}
+	}
+	for k, a := range ml.sources {
+		if a.stale(now, ml.source) {
+			delete(ml.sources, k)
+		}
+	}
+	// Sweeping is linear, so don't do it again until the maps have doubled.
+	ml.sweepAt = 2 * (len(ml.identities) + len(ml.sources))
+	if ml.sweepAt < 1024 {
+		ml.sweepAt = 1024
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,178 @@
+package srp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimitPolicyDelay(t *testing.T) {
+	p := LimitPolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: 10 * time.Second, LockoutThreshold: 10}.withDefaults()
+	want := []time.Duration{0, 0, 0, 1, 2, 4, 8, 10, 10, 10}
+	for failures, w := range want {
+		if d := p.delay(failures); d != w*time.Second {
+			t.Errorf("delay after %d failures is %v, want %v", failures, d, w*time.Second)
+		}
+	}
+	if d := p.delay(10); d != DefaultLockoutDuration {
+		t.Errorf("delay at lockout is %v", d)
+	}
+	if d := (LimitPolicy{}).withDefaults().delay(1000); d != DefaultMaxDelay {
+		t.Errorf("delay without lockout is %v", d)
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1600000000, 0)
+	ml := NewMemoryLimiter(&LimiterConfig{
+		Identity: LimitPolicy{FreeAttempts: 1, BaseDelay: time.Second, LockoutThreshold: 4, LockoutDuration: time.Hour, ResetAfter: 24 * time.Hour},
+		Source:   LimitPolicy{FreeAttempts: 5, BaseDelay: time.Minute},
+		Now:      func() time.Time { return now },
+	})
+	attempt := func(identity, source string, want time.Duration) {
+		t.Helper()
+		wait, err := ml.Check(ctx, identity, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != want {
+			t.Errorf("Check(%q, %q) = %v, want %v", identity, source, wait, want)
+		}
+	}
+	fail := func(identity, source string) {
+		t.Helper()
+		if err := ml.Failed(ctx, identity, source); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Checks alone don't count.
+	for i := 0; i < 10; i++ {
+		attempt("alice", "10.0.0.1", 0)
+	}
+	fail("alice", "10.0.0.1")
+	attempt("alice", "10.0.0.1", 0)
+	fail("alice", "10.0.0.1")
+	attempt("alice", "10.0.0.1", time.Second)
+	attempt("alice", "10.0.0.2", time.Second) // per identity
+	attempt("bob", "10.0.0.1", 0)
+	now = now.Add(time.Second)
+	attempt("alice", "10.0.0.1", 0)
+	fail("alice", "10.0.0.1")
+	attempt("alice", "10.0.0.1", 2*time.Second)
+	now = now.Add(2 * time.Second)
+	fail("alice", "10.0.0.1")
+	attempt("alice", "10.0.0.1", time.Hour) // locked out
+	now = now.Add(30 * time.Minute)
+	attempt("alice", "10.0.0.1", 30*time.Minute)
+
+	// A success clears the identity but not the source, which has four failures.
+	if err := ml.Succeeded(ctx, "alice", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	attempt("alice", "10.0.0.2", 0)
+	fail("carol", "10.0.0.1")
+	attempt("dave", "10.0.0.1", 0)
+	fail("dave", "10.0.0.1")
+	attempt("erin", "10.0.0.1", time.Minute) // six failures from the source
+	attempt("erin", "", 0)
+
+	// Failures are forgotten after ResetAfter.
+	fail("frank", "")
+	fail("frank", "")
+	attempt("frank", "", time.Second)
+	now = now.Add(24 * time.Hour)
+	attempt("frank", "", 0)
+	fail("frank", "")
+	attempt("frank", "", 0)
+}
+
+func TestMemoryLimiterReservations(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1600000000, 0)
+	ml := NewMemoryLimiter(&LimiterConfig{
+		Identity: LimitPolicy{FreeAttempts: 1, BaseDelay: time.Second},
+		Source:   LimitPolicy{FreeAttempts: 3, BaseDelay: time.Minute},
+		Now:      func() time.Time { return now },
+	})
+
+	// Of proofs checked at once, only as many get slots as could fail in a row
+	// without a wait.
+	const n = 16
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := ml.Attempt(ctx, "alice", "")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if wait == 0 {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if reserved != 2 {
+		t.Fatalf("%d of %d concurrent attempts reserved a slot, want 2", reserved, n)
+	}
+	if wait, _ := ml.Check(ctx, "alice", ""); wait != time.Second {
+		t.Errorf("Check with two slots reserved = %v, want 1s", wait)
+	}
+	// Failing them counts them as the failures they stood for.
+	for i := 0; i < reserved; i++ {
+		if err := ml.Failed(ctx, "alice", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait, _ := ml.Attempt(ctx, "alice", ""); wait != time.Second {
+		t.Errorf("Attempt after two failures = %v, want 1s", wait)
+	}
+
+	// A success gives the source its slot back without clearing its failures.
+	for i := 0; i < 3; i++ {
+		if err := ml.Failed(ctx, "bob", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait, _ := ml.Attempt(ctx, "carol", "10.0.0.1"); wait != 0 {
+		t.Fatalf("Attempt = %v", wait)
+	}
+	if wait, _ := ml.Check(ctx, "dave", "10.0.0.1"); wait != time.Minute {
+		t.Errorf("Check with a slot reserved = %v, want 1m", wait)
+	}
+	if err := ml.Succeeded(ctx, "carol", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := ml.Check(ctx, "dave", "10.0.0.1"); wait != 0 {
+		t.Errorf("Check after the slot was released = %v", wait)
+	}
+
+	// A slot that is never released is forgotten after ResetAfter.
+	if wait, _ := ml.Attempt(ctx, "erin", ""); wait != 0 {
+		t.Fatalf("Attempt = %v", wait)
+	}
+	if wait, _ := ml.Attempt(ctx, "erin", ""); wait != 0 {
+		t.Fatalf("Attempt = %v", wait)
+	}
+	if wait, _ := ml.Check(ctx, "erin", ""); wait == 0 {
+		t.Error("two reserved slots didn't count")
+	}
+	now = now.Add(DefaultResetAfter)
+	if wait, _ := ml.Check(ctx, "erin", ""); wait != 0 {
+		t.Errorf("Check a day after the slots were reserved = %v", wait)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,123 @@
+package srp
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a VerifierStore that keeps records in memory.
+// It is mostly useful for tests.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[memoryKey]*VerifierRecord
+}
+
+type memoryKey struct {
+	identity, credential string
+}
+
+var (
+	_ VerifierStore  = &MemoryStore{} //nolint:exhaustruct
+	_ VerifierLister = &MemoryStore{} //nolint:exhaustruct
+)
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[memoryKey]*VerifierRecord)}
+}
+
+// Lookup returns a copy of the record for the credential or ErrUnknownIdentity.
+func (ms *MemoryStore) Lookup(_ context.Context, identity, credential string) (*VerifierRecord, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	rec, ok := ms.records[memoryKey{identity, credential}]
+	if !ok {
+		return nil, ErrUnknownIdentity
+	}
+	return copyRecord(rec), nil
+}
+
+// Enroll stores a copy of rec with version 1.
+func (ms *MemoryStore) Enroll(_ context.Context, rec *VerifierRecord) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := memoryKey{rec.Identity, rec.Credential}
+	if _, ok := ms.records[key]; ok {
+		return ErrIdentityExists
+	}
+	rec.Version = 1
+	ms.records[key] = copyRecord(rec)
+	return nil
+}
+
+// Update replaces the record for rec.Identity and rec.Credential if the versions match.
+func (ms *MemoryStore) Update(_ context.Context, rec *VerifierRecord) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := memoryKey{rec.Identity, rec.Credential}
+	stored, ok := ms.records[key]
+	if !ok {
+		return ErrUnknownIdentity
+	}
+	if stored.Version != rec.Version {
+		return ErrVersionConflict
+	}
+	rec.Version++
+	ms.records[key] = copyRecord(rec)
+	return nil
+}
+
+// Identities returns the enrolled identities in sorted order.
+func (ms *MemoryStore) Identities(_ context.Context) ([]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	seen := make(map[string]bool)
+	ids := make([]string, 0, len(ms.records))
+	for key := range ms.records {
+		if !seen[key.identity] {
+			seen[key.identity] = true
+			ids = append(ids, key.identity)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Credentials returns the credential names of identity in sorted order.
+func (ms *MemoryStore) Credentials(_ context.Context, identity string) ([]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var names []string
+	for key := range ms.records {
+		if key.identity == identity {
+			names = append(names, key.credential)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func copyRecord(rec *VerifierRecord) *VerifierRecord {
+	c := *rec
+	c.Salt = append([]byte(nil), rec.Salt...)
+	if rec.Verifier != nil {
+		c.Verifier = new(big.Int).Set(rec.Verifier)
+	}
+	if rec.SealedVerifier != nil {
+		c.SealedVerifier = append([]byte(nil), rec.SealedVerifier...)
+	}
+	return &c
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,11 @@
+//go:build !race
+// +build !race
+
+package srp
+
+const raceEnabled = false
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
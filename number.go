@@ -0,0 +1,122 @@
+package srp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// NumberEncoding is a text encoding of a non-negative number understood by ParseNumber.
+type NumberEncoding int
+
+const (
+	// Hex is big endian hex digits, in either case, with no prefix.
+	Hex NumberEncoding = iota + 1
+
+	// Base64URL is the big endian bytes of the number in unpadded base64url (RFC 4648 section 5).
+	Base64URL
+
+	// Decimal is decimal digits.
+	Decimal
+)
+
+func (e NumberEncoding) String() string {
+	switch e {
+	case Hex:
+		return "hex"
+	case Base64URL:
+		return "base64url"
+	case Decimal:
+		return "decimal"
+	default:
+		return fmt.Sprintf("NumberEncoding(%d)", int(e))
+	}
+}
+
+// maxLen is the longest encoding of a number of MaxNumberSize bytes.
+func (e NumberEncoding) maxLen() int {
+	switch e {
+	case Hex:
+		return 2 * MaxNumberSize
+	case Base64URL:
+		return base64.RawURLEncoding.EncodedLen(MaxNumberSize)
+	case Decimal:
+		// log10(256) < 2.41
+		return MaxNumberSize*241/100 + 1
+	default:
+		return 0
+	}
+}
+
+/*
+ParseNumber parses s, a number in the encoding enc, from an untrusted source.
+
+Unlike NumberFromString, it reports what is wrong with s instead of quietly
+returning zero. It accepts only the digits of the encoding: no signs,
+prefixes, spaces or underscores, and nothing encoding more than MaxNumberSize
+bytes. Leading zeros are allowed. Use ParseNumberInRange to bound the result.
+*/
+func ParseNumber(s string, enc NumberEncoding) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty %s number", enc)
+	}
+	if len(s) > enc.maxLen() {
+		return nil, fmt.Errorf("%s number is too long", enc)
+	}
+
+	var digits string
+	switch enc {
+	case Hex:
+		digits = "0123456789abcdefABCDEF"
+	case Decimal:
+		digits = "0123456789"
+	case Base64URL:
+		b, err := base64.RawURLEncoding.Strict().DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("malformed base64url number: %w", err)
+		}
+		return new(big.Int).SetBytes(b), nil
+	default:
+		return nil, fmt.Errorf("unknown number encoding %d", int(enc))
+	}
+	if strings.Trim(s, digits) != "" {
+		return nil, fmt.Errorf("malformed %s number", enc)
+	}
+	base := 16
+	if enc == Decimal {
+		base = 10
+	}
+	n, ok := new(big.Int).SetString(s, base)
+	if !ok {
+		return nil, fmt.Errorf("malformed %s number", enc)
+	}
+	return n, nil
+}
+
+/*
+ParseNumberInRange is ParseNumber, additionally requiring that min <= n < max.
+A nil min or max leaves that end unbounded.
+
+A verifier for a group, for instance, belongs in [2, N):
+
+	v, err := ParseNumberInRange(s, Hex, big.NewInt(2), group.N())
+*/
+func ParseNumberInRange(s string, enc NumberEncoding, min, max *big.Int) (*big.Int, error) {
+	n, err := ParseNumber(s, enc)
+	if err != nil {
+		return nil, err
+	}
+	if min != nil && n.Cmp(min) < 0 {
+		return nil, fmt.Errorf("number is less than the minimum")
+	}
+	if max != nil && n.Cmp(max) >= 0 {
+		return nil, fmt.Errorf("number isn't less than the maximum")
+	}
+	return n, nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
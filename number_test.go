@@ -0,0 +1,85 @@
+package srp
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	good := []struct {
+		s    string
+		enc  NumberEncoding
+		want int64
+	}{
+		{"ff", Hex, 255},
+		{"00FF", Hex, 255},
+		{"0", Hex, 0},
+		{"_w", Base64URL, 255},
+		{"AP8", Base64URL, 255},
+		{"255", Decimal, 255},
+		{"0255", Decimal, 255},
+	}
+	for _, tc := range good {
+		n, err := ParseNumber(tc.s, tc.enc)
+		if err != nil {
+			t.Errorf("ParseNumber(%q, %s): %v", tc.s, tc.enc, err)
+			continue
+		}
+		if n.Int64() != tc.want {
+			t.Errorf("ParseNumber(%q, %s) = %v, want %d", tc.s, tc.enc, n, tc.want)
+		}
+	}
+
+	bad := []struct {
+		s   string
+		enc NumberEncoding
+	}{
+		{"", Hex},
+		{"0x1234", Hex},
+		{"-1", Hex},
+		{"+1", Decimal},
+		{"12 34", Hex},
+		{"12_34", Hex},
+		{"fg", Hex},
+		{"ff", Decimal},
+		{"1e3", Decimal},
+		{"AP8=", Base64URL},
+		{"AP+", Base64URL},
+		{"A", Base64URL},
+		{"AP9", Base64URL}, // non-zero trailing bits
+		{strings.Repeat("f", 2*MaxNumberSize+1), Hex},
+		{strings.Repeat("9", 2*MaxNumberSize+1000), Decimal},
+		{"1", NumberEncoding(0)},
+	}
+	for _, tc := range bad {
+		if n, err := ParseNumber(tc.s, tc.enc); err == nil {
+			t.Errorf("ParseNumber(%q, %s) = %v, want error", tc.s, tc.enc, n)
+		}
+	}
+}
+
+func TestParseNumberInRange(t *testing.T) {
+	N := KnownGroups[RFC5054Group2048].N()
+	one := big.NewInt(1)
+	nMinusOne := new(big.Int).Sub(N, one)
+
+	for _, s := range []string{"1", nMinusOne.Text(16)} {
+		if _, err := ParseNumberInRange(s, Hex, one, N); err != nil {
+			t.Errorf("%s rejected: %v", s, err)
+		}
+	}
+	for _, s := range []string{"0", N.Text(16), new(big.Int).Add(N, one).Text(16)} {
+		if _, err := ParseNumberInRange(s, Hex, one, N); err == nil {
+			t.Errorf("%s accepted", s)
+		}
+	}
+	if _, err := ParseNumberInRange("0", Decimal, nil, nil); err != nil {
+		t.Errorf("unbounded range rejected 0: %v", err)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
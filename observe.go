@@ -0,0 +1,87 @@
+package srp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/*
+An Observer is told about the expensive and the security relevant steps of
+every handshake in the process: computing A or B, computing the key, checking
+proofs, and rejecting values from the other party. Package srpprom has one that
+keeps Prometheus metrics.
+
+There is one Observer for the process, set with SetObserver. Until it is set
+the cost of the hooks is an atomic load, and nothing is timed.
+*/
+
+// Observer receives handshake events. Its methods are called synchronously
+// from the handshake and from many goroutines at once, so they must be quick and
+// safe for concurrent use.
+type Observer interface {
+	// HandshakeStarted is called when A (for a client) or B (for a server)
+	// has been computed, with the time that took.
+	HandshakeStarted(info HandshakeInfo, d time.Duration)
+	// KeyDerived is called when Key has computed the key, with the time that took.
+	KeyDerived(info HandshakeInfo, d time.Duration)
+	// ProofChecked is called when GoodServerProof or GoodClientProof has checked
+	// the other party's proof, with the result. For a fake server ok is always false.
+	ProofChecked(info HandshakeInfo, ok bool, d time.Duration)
+	// Rejected is called when a value from the other party is rejected.
+	Rejected(info HandshakeInfo, reason RejectReason)
+}
+
+// HandshakeInfo describes the handshake an event is from.
+type HandshakeInfo struct {
+	Group  string // the group's label
+	Server bool   // whether the event is on the server's side
+}
+
+// RejectReason says why a value from the other party was rejected.
+type RejectReason string
+
+// Reasons for rejections
+const (
+	// RejectInvalidPublic is a public value that is zero or one modulo N.
+	RejectInvalidPublic RejectReason = "invalid_public"
+	// RejectPublicOutOfRange is a public value that isn't less than N.
+	RejectPublicOutOfRange RejectReason = "public_out_of_range"
+	// RejectMalformedPublic is an encoding of a public value that isn't well formed.
+	RejectMalformedPublic RejectReason = "malformed_public"
+	// RejectInvalidU is a pair of public values that hash to a u of zero.
+	RejectInvalidU RejectReason = "invalid_u"
+)
+
+type observerBox struct{ o Observer }
+
+var observer atomic.Value // of observerBox
+
+// SetObserver sets the Observer for all handshakes in the process. Nil removes it.
+func SetObserver(o Observer) {
+	observer.Store(observerBox{o})
+}
+
+func currentObserver() Observer {
+	box, _ := observer.Load().(observerBox)
+	return box.o
+}
+
+func (s *SRP) handshakeInfo() HandshakeInfo {
+	info := HandshakeInfo{Server: s.isServer}
+	if s.group != nil {
+		info.Group = s.group.Label
+	}
+	return info
+}
+
+// observeRejected tells the Observer, if any, about a rejection.
+func (s *SRP) observeRejected(reason RejectReason) {
+	if obs := currentObserver(); obs != nil {
+		obs.Rejected(s.handshakeInfo(), reason)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
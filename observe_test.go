@@ -0,0 +1,65 @@
+package srp
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+type recordingObserver struct {
+	started, keys int
+	proofs        []bool
+	rejected      []RejectReason
+}
+
+func (r *recordingObserver) HandshakeStarted(HandshakeInfo, time.Duration) { r.started++ }
+func (r *recordingObserver) KeyDerived(HandshakeInfo, time.Duration)       { r.keys++ }
+func (r *recordingObserver) ProofChecked(_ HandshakeInfo, ok bool, _ time.Duration) {
+	r.proofs = append(r.proofs, ok)
+}
+func (r *recordingObserver) Rejected(_ HandshakeInfo, reason RejectReason) {
+	r.rejected = append(r.rejected, reason)
+}
+
+func TestObserver(t *testing.T) {
+	obs := &recordingObserver{}
+	SetObserver(obs)
+	defer SetObserver(nil)
+
+	grp := KnownGroups[RFC5054Group2048]
+	server := NewServerStd(grp, big.NewInt(1234))
+	client := NewClientStd(grp, big.NewInt(5678))
+	if err := server.SetOthersPublic(client.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Key(); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = server.Key() // cached, so not observed again
+	server.GoodClientProof([]byte("nonsense"))
+
+	_ = NewServerStd(grp, bigOne).SetOthersPublic(bigOne)
+	_ = NewServerStd(grp, bigOne).SetOthersPublicHex(grp.N().Text(16))
+	_ = NewServerStd(grp, bigOne).SetOthersPublicBytes([]byte{1})
+
+	if obs.started != 5 || obs.keys != 1 {
+		t.Errorf("%d started and %d keys", obs.started, obs.keys)
+	}
+	if len(obs.proofs) != 1 || obs.proofs[0] {
+		t.Errorf("proofs %v", obs.proofs)
+	}
+	want := []RejectReason{RejectInvalidPublic, RejectPublicOutOfRange, RejectMalformedPublic}
+	if len(obs.rejected) != len(want) {
+		t.Fatalf("rejected %v, want %v", obs.rejected, want)
+	}
+	for i := range want {
+		if obs.rejected[i] != want[i] {
+			t.Errorf("rejected %v, want %v", obs.rejected, want)
+		}
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
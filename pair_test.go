@@ -0,0 +1,82 @@
+package srp
+
+import (
+	"math/big"
+	"testing"
+)
+
+// A profile is a way of computing k and u: as RFC 5054 says, or the original 1Password way.
+type profile struct {
+	name      string
+	newClient func(grp *Group, x *big.Int) *SRP
+	newServer func(grp *Group, v *big.Int) *SRP
+}
+
+var profiles = []profile{
+	{"RFC5054", NewClientStd, NewServerStd},
+	{
+		"1Password",
+		func(grp *Group, x *big.Int) *SRP { return NewSRPClient(grp, x, nil) },
+		func(grp *Group, v *big.Int) *SRP { return NewSRPServer(grp, v, nil) },
+	},
+}
+
+// std is the RFC 5054 profile, which most tests use.
+var std = profiles[0]
+
+// keyedPair returns a client with x and a server with v in grp, made as p
+// makes them, that have exchanged public values and computed the key. If v is
+// nil, it is x's verifier.
+func keyedPair(tb testing.TB, p profile, grp *Group, x, v *big.Int) (client, server *SRP) {
+	tb.Helper()
+	if v == nil {
+		var err error
+		if v, err = p.newClient(grp, x).Verifier(); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	client, server = p.newClient(grp, x), p.newServer(grp, v)
+	if client == nil || server == nil {
+		tb.Fatal("failed to create client or server")
+	}
+	if err := client.SetOthersPublic(server.EphemeralPublic()); err != nil {
+		tb.Fatal(err)
+	}
+	if err := server.SetOthersPublic(client.EphemeralPublic()); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := client.Key(); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := server.Key(); err != nil {
+		tb.Fatal(err)
+	}
+	return client, server
+}
+
+// authenticatedPair runs a complete handshake for an enrolled identity and
+// returns the client and server once both have proved themselves.
+func authenticatedPair(tb testing.TB, rec *VerifierRecord, password string) (client, server *SRP) {
+	tb.Helper()
+	client, server = keyedPair(tb, std, rec.Group(), KDFRFC5054(rec.Salt, rec.Identity, password), rec.Verifier)
+	m, err := server.M(rec.Salt, rec.Identity)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if !client.GoodServerProof(rec.Salt, rec.Identity, m) {
+		tb.Fatal("bad server proof")
+	}
+	cProof, err := client.ClientProof()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if !server.GoodClientProof(cProof) {
+		tb.Fatal("bad client proof")
+	}
+	return client, server
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
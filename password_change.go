@@ -0,0 +1,191 @@
+package srp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+/*
+A password change replaces the salt, KDF parameters, group and verifier in
+an identity's VerifierRecord. Doing that over an authenticated SRP session
+means that nothing beyond the session itself is needed to protect it:
+
+	Client: (after GoodServerProof and sending ClientProof)
+	        x' = KDF(s', new password)
+	        v' = g'^x'
+	        C  = SealPasswordChange(I, s', group', KDF', v')
+	Client -> Server: C
+
+	Server: (after GoodClientProof)
+	        ChangePassword(store, I, C)
+
+C is encrypted and authenticated with AES-256-GCM under a key derived from the
+session key, with I as associated data. Only a party that knows the session key
+can construct C, and the server only accepts it from a session in which the
+client has proved knowledge of that key. A session can be used for at most one
+password change, so C can't be replayed.
+*/
+
+// passwordChangeLabel is used with sealWithSessionKey.
+const passwordChangeLabel = "SRP password change v1"
+
+// ErrBadCredentialChange is returned for a sealed credential change that can't be
+// opened, or that doesn't hold an acceptable record.
+var ErrBadCredentialChange = errors.New("bad credential change")
+
+// passwordChange is the plaintext of a sealed password change.
+type passwordChange struct {
+	Salt     []byte    `json:"salt"`
+	GroupID  int       `json:"group"`
+	KDF      KDFParams `json:"kdf"`
+	Verifier []byte    `json:"v"`
+}
+
+// SealPasswordChange is called by the client to construct a request to replace
+// its verifier record with one for new salt, group, KDF and verifier.
+// It may only be called once the server has proved itself.
+func (s *SRP) SealPasswordChange(identity string, salt []byte, groupID int, kdf KDFParams, v *big.Int) ([]byte, error) {
+	return s.SealCredentialChange(identity, PrimaryCredential, salt, groupID, kdf, v)
+}
+
+// SealCredentialChange is SealPasswordChange for any credential of identity.
+// The change applies to the credential that the session was authenticated with,
+// which must be the one named.
+func (s *SRP) SealCredentialChange(identity, credential string, salt []byte, groupID int, kdf KDFParams, v *big.Int) ([]byte, error) {
+	name, err := credentialName(identity, credential)
+	if err != nil {
+		return nil, err
+	}
+	return s.sealCredentialChange(passwordChangeLabel, name, salt, groupID, kdf, v)
+}
+
+// sealCredentialChange seals a passwordChange for adName with label.
+func (s *SRP) sealCredentialChange(label, adName string, salt []byte, groupID int, kdf KDFParams, v *big.Int) ([]byte, error) {
+	if s.isServer {
+		return nil, fmt.Errorf("only the client may seal a credential change")
+	}
+	if !s.isServerProved {
+		return nil, fmt.Errorf("don't change credentials until server is proved")
+	}
+	if v == nil {
+		return nil, fmt.Errorf("no verifier given")
+	}
+	plaintext, err := json.Marshal(passwordChange{
+		Salt:     salt,
+		GroupID:  groupID,
+		KDF:      kdf,
+		Verifier: v.Bytes(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode credential change: %w", err)
+	}
+	return s.sealWithSessionKey(label, adName, plaintext)
+}
+
+/*
+OpenPasswordChange is called by the server to open a sealed password change.
+It may only be called once GoodClientProof has succeeded, and it will only
+succeed once per session.
+
+The returned record holds the new salt, group, KDF and verifier for identity.
+Most callers will want ChangePassword instead.
+*/
+func (s *SRP) OpenPasswordChange(identity string, sealed []byte) (*VerifierRecord, error) {
+	name, err := credentialName(identity, PrimaryCredential)
+	if err != nil {
+		return nil, err
+	}
+	return s.openCredentialChange(passwordChangeLabel, identity, name, sealed)
+}
+
+// openCredentialChange opens a passwordChange sealed for adName with label,
+// checks it and returns it as a record for identity.
+func (s *SRP) openCredentialChange(label, identity, adName string, sealed []byte) (*VerifierRecord, error) {
+	if !s.isServer {
+		return nil, fmt.Errorf("only the server may open a credential change")
+	}
+	if !s.isClientProved {
+		return nil, fmt.Errorf("don't accept a credential change until client is proved")
+	}
+	if s.credChanged {
+		return nil, fmt.Errorf("%w: session has already been used to change a credential", ErrBadCredentialChange)
+	}
+	plaintext, err := s.openWithSessionKey(label, adName, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to open: %v", ErrBadCredentialChange, err)
+	}
+	// Whatever happens from here on, this session has had its go.
+	s.credChanged = true
+
+	var pc passwordChange
+	if err := json.Unmarshal(plaintext, &pc); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode: %v", ErrBadCredentialChange, err)
+	}
+
+	grp := KnownGroups[pc.GroupID]
+	if grp == nil || grp.n.BitLen() < MinGroupSize {
+		return nil, fmt.Errorf("%w: unacceptable group %d", ErrBadCredentialChange, pc.GroupID)
+	}
+	if len(pc.Salt) == 0 {
+		return nil, fmt.Errorf("%w: no salt", ErrBadCredentialChange)
+	}
+	v := new(big.Int).SetBytes(pc.Verifier)
+	if v.Cmp(bigOne) <= 0 || v.Cmp(grp.n) >= 0 {
+		return nil, fmt.Errorf("%w: invalid verifier", ErrBadCredentialChange)
+	}
+
+	return &VerifierRecord{
+		Identity: identity,
+		Salt:     pc.Salt,
+		GroupID:  pc.GroupID,
+		KDF:      pc.KDF,
+		Verifier: v,
+	}, nil
+}
+
+/*
+ChangePassword opens a sealed password change with server and applies it to rec,
+the record that server was created from, in store.
+
+The update is made with the version rec was read at, so if the record has changed
+since the session started (because of another password change, say) the change
+is refused with ErrVersionConflict. On success rec holds the new record.
+*/
+func ChangePassword(ctx context.Context, store VerifierStore, server *SRP, rec *VerifierRecord, sealed []byte) error {
+	return applyPasswordChange(ctx, store, server, rec, sealed, nil)
+}
+
+// applyPasswordChange does the work of ChangePassword, additionally rejecting the
+// new record if accept is not nil and returns an error for it.
+func applyPasswordChange(ctx context.Context, store VerifierStore, server *SRP, rec *VerifierRecord, sealed []byte,
+	accept func(*VerifierRecord) error,
+) error {
+	adName, err := credentialName(rec.Identity, rec.Credential)
+	if err != nil {
+		return err
+	}
+	changed, err := server.openCredentialChange(passwordChangeLabel, rec.Identity, adName, sealed)
+	if err != nil {
+		return err
+	}
+	if accept != nil {
+		if err := accept(changed); err != nil {
+			return err
+		}
+	}
+	changed.Credential = rec.Credential
+	changed.Version = rec.Version
+	if err := store.Update(ctx, changed); err != nil {
+		return err
+	}
+	*rec = *changed
+	return nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,144 @@
+package srp
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// enroll makes a verifier record for identity and password and enrolls it in store.
+func enroll(t *testing.T, store VerifierStore, identity, password string, groupID int) *VerifierRecord {
+	t.Helper()
+	salt := []byte("salt for " + identity)
+	x := KDFRFC5054(salt, identity, password)
+	v, err := NewClientStd(KnownGroups[groupID], x).Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &VerifierRecord{
+		Identity: identity,
+		Salt:     salt,
+		GroupID:  groupID,
+		KDF:      KDFParams{Alg: "rfc5054", Iterations: 1},
+		Verifier: v,
+	}
+	if err := store.Enroll(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	identity := "polly@cracker.example"
+	rec := enroll(t, store, identity, "old password", RFC5054Group2048)
+
+	client, server := authenticatedPair(t, rec, "old password")
+
+	newSalt := []byte("new salt")
+	newGroup := RFC5054Group3072
+	newX := KDFRFC5054(newSalt, identity, "new password")
+	newV, err := NewClientStd(KnownGroups[newGroup], newX).Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdf := KDFParams{Alg: "rfc5054", Iterations: 2}
+	sealed, err := client.SealPasswordChange(identity, newSalt, newGroup, kdf, newV)
+	if err != nil {
+		t.Fatalf("failed to seal password change: %s", err)
+	}
+
+	// The wrong identity must not be able to use it
+	other := *rec
+	other.Identity = "someone@else.example"
+	if err := ChangePassword(ctx, store, server, &other, sealed); err == nil {
+		t.Error("password change accepted for wrong identity")
+	}
+
+	if err := ChangePassword(ctx, store, server, rec, sealed); err != nil {
+		t.Fatalf("password change failed: %s", err)
+	}
+	if err := ChangePassword(ctx, store, server, rec, sealed); err == nil {
+		t.Error("replayed password change accepted")
+	}
+
+	stored, err := store.Lookup(ctx, identity, PrimaryCredential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Verifier.Cmp(newV) != 0 || stored.GroupID != newGroup || stored.KDF != kdf {
+		t.Error("stored record wasn't changed")
+	}
+
+	// And the new password works
+	authenticatedPair(t, stored, "new password")
+}
+
+func TestChangePasswordRequiresProof(t *testing.T) {
+	store := NewMemoryStore()
+	rec := enroll(t, store, "alice", "password123", RFC5054Group2048)
+
+	client := NewClientStd(rec.Group(), KDFRFC5054(rec.Salt, rec.Identity, "password123"))
+	if _, err := client.SealPasswordChange(rec.Identity, rec.Salt, rec.GroupID, rec.KDF, rec.Verifier); err == nil {
+		t.Error("client sealed password change before server was proved")
+	}
+
+	client, server := authenticatedPair(t, rec, "password123")
+	sealed, err := client.SealPasswordChange(rec.Identity, rec.Salt, rec.GroupID, rec.KDF, rec.Verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.isClientProved = false
+	if _, err := server.OpenPasswordChange(rec.Identity, sealed); err == nil {
+		t.Error("server opened password change before client was proved")
+	}
+}
+
+func TestChangePasswordInvalidName(t *testing.T) {
+	store := NewMemoryStore()
+	rec := enroll(t, store, "alice", "password123", RFC5054Group2048)
+	client, server := authenticatedPair(t, rec, "password123")
+	sealed, err := client.SealPasswordChange(rec.Identity, rec.Salt, rec.GroupID, rec.KDF, rec.Verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.OpenPasswordChange("alice\x00recovery-1", sealed); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("expected ErrInvalidName, got %v", err)
+	}
+	// Having refused the name, the server can still open the change for the right one.
+	if _, err := server.OpenPasswordChange(rec.Identity, sealed); err != nil {
+		t.Errorf("failed to open password change: %s", err)
+	}
+
+	bad := *rec
+	bad.Identity = "bob\x00recovery-1"
+	if err := store.Enroll(context.Background(), &bad); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("expected ErrInvalidName enrolling, got %v", err)
+	}
+}
+
+func TestChangePasswordStaleRecord(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	rec := enroll(t, store, "alice", "password123", RFC5054Group2048)
+
+	client1, server1 := authenticatedPair(t, rec, "password123")
+	client2, server2 := authenticatedPair(t, rec, "password123")
+	rec1, rec2 := *rec, *rec
+
+	sealed1, _ := client1.SealPasswordChange(rec.Identity, []byte("one"), rec.GroupID, rec.KDF, rec.Verifier)
+	sealed2, _ := client2.SealPasswordChange(rec.Identity, []byte("two"), rec.GroupID, rec.KDF, rec.Verifier)
+
+	if err := ChangePassword(ctx, store, server1, &rec1, sealed1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ChangePassword(ctx, store, server2, &rec2, sealed2); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict, got %v", err)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,187 @@
+package srp
+
+import (
+	"context"
+	rand "crypto/rand"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+A server spends most of the time it takes to start a handshake computing g^b.
+An EphemeralPool computes (b, g^b) pairs ahead of time, in background
+goroutines, and a server created for the pool's group while the pool is running
+takes a pair from it, leaving only kv + g^b to compute. When the pool is empty
+the server computes its own pair, as it does with no pool.
+
+Each pair is handed out once and then forgotten by the pool. Pairs that are
+ready when the pool's context is done are dropped.
+*/
+
+// DefaultEphemeralPoolSize is the number of pairs an EphemeralPool keeps ready unless configured otherwise.
+const DefaultEphemeralPoolSize = 64
+
+// EphemeralPoolConfig configures an EphemeralPool. The zero EphemeralPoolConfig uses the defaults.
+type EphemeralPoolConfig struct {
+	Size    int // pairs to keep ready; zero means DefaultEphemeralPoolSize
+	Workers int // goroutines computing pairs; zero means one
+}
+
+// EphemeralPoolStats counts what an EphemeralPool has done.
+type EphemeralPoolStats struct {
+	Hits   uint64 // servers that took a pair
+	Misses uint64 // servers that found the pool empty
+	Ready  int    // pairs ready now
+}
+
+// EphemeralPool keeps server ephemeral pairs ready for a group. It is safe for concurrent use.
+type EphemeralPool struct {
+	hits, misses uint64 // first, for atomic access on 32 bit platforms
+
+	group *Group
+	ctx   context.Context
+	pairs chan ephemeralPair
+	done  chan struct{}
+}
+
+type ephemeralPair struct {
+	b, gb *big.Int
+}
+
+var (
+	poolsMu sync.RWMutex
+	pools   = make(map[*Group]*EphemeralPool) // running pools, by group
+)
+
+/*
+NewEphemeralPool starts filling a pool of pairs for servers created with group,
+and runs until ctx is done. There may be only one running pool per group.
+cfg may be nil.
+*/
+func NewEphemeralPool(ctx context.Context, group *Group, cfg *EphemeralPoolConfig) (*EphemeralPool, error) {
+	if group == nil || group.n == nil || group.g == nil {
+		return nil, fmt.Errorf("group not set")
+	}
+	var c EphemeralPoolConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Size <= 0 {
+		c.Size = DefaultEphemeralPoolSize
+	}
+	if c.Workers <= 0 {
+		c.Workers = 1
+	}
+	p := &EphemeralPool{
+		hits:   0,
+		misses: 0,
+		group:  group,
+		ctx:    ctx,
+		pairs:  make(chan ephemeralPair, c.Size),
+		done:   make(chan struct{}),
+	}
+
+	poolsMu.Lock()
+	if pools[group] != nil {
+		poolsMu.Unlock()
+		return nil, fmt.Errorf("group %s already has an ephemeral pool", group.Label)
+	}
+	pools[group] = p
+	poolsMu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.fill()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		poolsMu.Lock()
+		delete(pools, group)
+		poolsMu.Unlock()
+		// Drop the pairs that are left, so that none outlives the pool.
+		for {
+			select {
+			case <-p.pairs:
+			default:
+				close(p.done)
+				return
+			}
+		}
+	}()
+	return p, nil
+}
+
+// fill computes pairs until the pool's context is done.
+func (p *EphemeralPool) fill() {
+	eSize := maxInt(p.group.ExponentSize, MinExponentSize)
+	for {
+		bytes := make([]byte, eSize)
+		if _, err := rand.Read(bytes); err != nil {
+			// If we can't get random bytes from the system, then we have no business doing anything crypto related.
+			panic(fmt.Sprintf("Failed to get random bytes: %v", err))
+		}
+		b := new(big.Int).SetBytes(bytes)
+		pair := ephemeralPair{b: b, gb: p.group.expG(new(big.Int), b)}
+		select {
+		case p.pairs <- pair:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// Group returns the group the pool is for.
+func (p *EphemeralPool) Group() *Group {
+	return p.group
+}
+
+// Stats returns the pool's counts so far.
+func (p *EphemeralPool) Stats() EphemeralPoolStats {
+	return EphemeralPoolStats{
+		Hits:   atomic.LoadUint64(&p.hits),
+		Misses: atomic.LoadUint64(&p.misses),
+		Ready:  len(p.pairs),
+	}
+}
+
+// Done returns a channel that is closed once the pool has stopped and dropped its pairs.
+func (p *EphemeralPool) Done() <-chan struct{} {
+	return p.done
+}
+
+// take returns a pair, or nils if the pool is empty or has stopped.
+func (p *EphemeralPool) take() (b, gb *big.Int) {
+	if p.ctx.Err() != nil {
+		return nil, nil
+	}
+	select {
+	case pair := <-p.pairs:
+		atomic.AddUint64(&p.hits, 1)
+		return pair.b, pair.gb
+	default:
+		atomic.AddUint64(&p.misses, 1)
+		return nil, nil
+	}
+}
+
+// takePooledEphemeral returns a pair from group's running pool, or nils if there isn't one to take.
+func takePooledEphemeral(group *Group) (b, gb *big.Int) {
+	poolsMu.RLock()
+	p := pools[group]
+	poolsMu.RUnlock()
+	if p == nil {
+		return nil, nil
+	}
+	return p.take()
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,74 @@
+package srp
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestEphemeralPool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	grp := freshTable(RFC5054Group2048) // a group of its own, so that other servers don't use the pool
+	pool, err := NewEphemeralPool(ctx, grp, &EphemeralPoolConfig{Size: 4, Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEphemeralPool(ctx, grp, nil); err == nil {
+		t.Error("second pool for a group was allowed")
+	}
+	for deadline := time.Now().Add(10 * time.Second); pool.Stats().Ready < 4; {
+		if time.Now().After(deadline) {
+			t.Fatal("pool didn't fill")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	x := big.NewInt(12345)
+	v := NewClientStd(grp, x)
+	verifier, err := v.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 6; i++ {
+		client, server := keyedPair(t, std, grp, x, verifier)
+		b := server.ephemeralPrivate.String()
+		if seen[b] {
+			t.Fatal("an ephemeral secret was used twice")
+		}
+		seen[b] = true
+		if string(client.key) != string(server.key) {
+			t.Fatal("keys don't match with a pooled pair")
+		}
+	}
+	if stats := pool.Stats(); stats.Hits < 4 || stats.Hits+stats.Misses != 6 {
+		t.Errorf("got %+v, want at least 4 hits out of 6", stats)
+	}
+
+	cancel()
+	select {
+	case <-pool.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("pool didn't stop")
+	}
+	before := pool.Stats()
+	if before.Ready != 0 {
+		t.Errorf("%d pairs left after stopping", before.Ready)
+	}
+	if NewServerStd(grp, verifier) == nil {
+		t.Fatal("failed to create server after the pool stopped")
+	}
+	if after := pool.Stats(); after != before {
+		t.Errorf("stopped pool was used: %+v", after)
+	}
+	if _, err := NewEphemeralPool(context.Background(), nil, nil); err == nil {
+		t.Error("pool without a group was allowed")
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
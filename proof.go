@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"fmt"
+	"time"
 )
 
 /*
@@ -28,52 +29,64 @@ func (s *SRP) M(salt []byte, uname string) ([]byte, error) {
 		return nil, fmt.Errorf("don't try to prove anything before you have the key")
 	}
 
+	sc := getScratch()
+	defer putScratch(sc)
+	buf := sc.bytes(s.group.byteLen())
+
 	// First lets work on the H(H(A) ⊕ H(g)) part.
-	nHash := sha256.Sum256(s.group.n.Bytes())
-	gHash := sha256.Sum256(s.group.g.Bytes())
-	groupXOR := make([]byte, sha256.Size)
-	if length := safeXORBytes(groupXOR, nHash[:], gHash[:]); length != sha256.Size {
+	nHash := sha256.Sum256(unpaddedBytes(buf, s.group.n))
+	gHash := sha256.Sum256(unpaddedBytes(buf, s.group.g))
+	var groupXOR [sha256.Size]byte
+	if length := safeXORBytes(groupXOR[:], nHash[:], gHash[:]); length != sha256.Size {
 		return nil, fmt.Errorf("XOR had %d bytes instead of %d",
 			length, sha256.Size)
 	}
-	groupHash := sha256.Sum256(groupXOR)
+	groupHash := sha256.Sum256(groupXOR[:])
 
 	uHash := sha256.Sum256([]byte(uname))
-	h := sha256.New()
+	h := sc.hash(Hash.Sha256Name)
 
-	if _, err := h.Write(groupHash[:]); err != nil {
-		return nil, fmt.Errorf("failed to write group hash to hasher: %w", err)
-	}
-	if _, err := h.Write(uHash[:]); err != nil {
-		return nil, fmt.Errorf("failed to write u hash to hasher: %w", err)
+	// Copied so as to be written from scratch space, as anything written to a
+	// hash.Hash escapes to the heap.
+	hashes := append(append(sc.sum[:0], groupHash[:]...), uHash[:]...)
+	if _, err := h.Write(hashes); err != nil {
+		return nil, fmt.Errorf("failed to write group and user name hashes to hasher: %w", err)
 	}
 	if _, err := h.Write(salt); err != nil {
 		return nil, fmt.Errorf("failed to write salt to hasher: %w", err)
 	}
-	if _, err := h.Write(s.ephemeralPublicA.Bytes()); err != nil {
+	if _, err := h.Write(unpaddedBytes(buf, s.ephemeralPublicA)); err != nil {
 		return nil, fmt.Errorf("failed to write A to hasher: %w", err)
 	}
-	if _, err := h.Write(s.ephemeralPublicB.Bytes()); err != nil {
+	if _, err := h.Write(unpaddedBytes(buf, s.ephemeralPublicB)); err != nil {
 		return nil, fmt.Errorf("failed to write B to hasher: %w", err)
 	}
 	if _, err := h.Write(s.key); err != nil {
 		return nil, fmt.Errorf("failed to write key to hasher: %w", err)
 	}
 
-	s.m = h.Sum(nil)
+	s.m = h.Sum(s.sums[1][:0])
 	return s.m, nil
 }
 
 // GoodServerProof takes the post-key negotiation proof from the server
 // and compares it with what we (the client) think it should be.
 func (s *SRP) GoodServerProof(salt []byte, uname string, proof []byte) bool {
+	obs := currentObserver()
+	var start time.Time
+	if obs != nil {
+		start = time.Now()
+	}
 	myM, err := s.M(salt, uname)
 	if err != nil {
 		// well that's odd. Better return false if something is wrong here
 		s.isServerProved = false
-		return false
+	} else {
+		s.isServerProved = subtle.ConstantTimeCompare(myM, proof) == 1
+	}
+	if obs != nil {
+		obs.ProofChecked(s.handshakeInfo(), s.isServerProved, time.Since(start))
 	}
-	s.isServerProved = subtle.ConstantTimeCompare(myM, proof) == 1
 	return s.isServerProved
 }
 
@@ -82,6 +95,32 @@ func (s *SRP) ClientProof() ([]byte, error) {
 	if !s.isServer && !s.isServerProved {
 		return nil, fmt.Errorf("don't construct client proof until server is proved")
 	}
+	return s.clientProof()
+}
+
+/*
+ClientProofFirst constructs the client's proof before the server has proved
+itself, for protocols in which the client shows its proof first, as the design
+above has it. It computes M itself from salt and uname; check the server's proof
+with GoodServerProof once the server has accepted this one.
+
+When the server proves itself first, anybody who starts a handshake can test
+password guesses against the server's proof offline. When the client does,
+only somebody posing as the server can, and a server can count real failed
+proofs, not just handshakes started.
+*/
+func (s *SRP) ClientProofFirst(salt []byte, uname string) ([]byte, error) {
+	if s.isServer {
+		return nil, fmt.Errorf("only a client has a proof to show first")
+	}
+	if _, err := s.M(salt, uname); err != nil {
+		return nil, err
+	}
+	return s.clientProof()
+}
+
+// clientProof constructs H(A, M, K), once M and the key are in place.
+func (s *SRP) clientProof() ([]byte, error) {
 	if s.cProof != nil {
 		return s.cProof, nil
 	}
@@ -89,8 +128,10 @@ func (s *SRP) ClientProof() ([]byte, error) {
 	if s.ephemeralPublicA == nil || s.m == nil || s.key == nil {
 		return nil, fmt.Errorf("not enough pieces in place to construct client proof")
 	}
-	h := sha256.New()
-	_, err := h.Write(s.ephemeralPublicA.Bytes())
+	sc := getScratch()
+	defer putScratch(sc)
+	h := sc.hash(Hash.Sha256Name)
+	_, err := h.Write(unpaddedBytes(sc.bytes(s.group.byteLen()), s.ephemeralPublicA))
 	if err != nil {
 		return nil, fmt.Errorf("failed to write A to hasher: %w", err)
 	}
@@ -102,17 +143,28 @@ func (s *SRP) ClientProof() ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to write key to hasher: %w", err)
 	}
-	s.cProof = h.Sum(nil)
+	s.cProof = h.Sum(s.sums[2][:0])
 	return s.cProof, nil
 }
 
 // GoodClientProof returns true if the given proof is the same as what we calculate.
 func (s *SRP) GoodClientProof(proof []byte) bool {
+	obs := currentObserver()
+	var start time.Time
+	if obs != nil {
+		start = time.Now()
+	}
 	myCP, err := s.ClientProof()
 	if err != nil {
-		return false
+		s.isClientProved = false
+	} else {
+		// Compare even for a fake server so that it takes as long as a real one.
+		s.isClientProved = subtle.ConstantTimeCompare(myCP, proof) == 1 && !s.isFake
+	}
+	if obs != nil {
+		obs.ProofChecked(s.handshakeInfo(), s.isClientProved, time.Since(start))
 	}
-	return subtle.ConstantTimeCompare(myCP, proof) == 1
+	return s.isClientProved
 }
 
 // lifted straight from https://golang.org/src/crypto/cipher/xor.go
@@ -54,6 +54,41 @@ func TestM(t *testing.T) {
 	}
 }
 
+func TestClientProofFirst(t *testing.T) {
+	salt, _ := hex.DecodeString("2e1a520e226f461e840e40e0")
+	username := "Polly@cracker.example"
+
+	client := new(SRP).copy(sampleSRP)
+	client.isServer = false
+
+	server := new(SRP).copy(sampleSRP)
+	server.isServer = true
+
+	if _, err := client.ClientProof(); err == nil {
+		t.Error("ClientProof worked before the server was proved")
+	}
+	proof, err := client.ClientProofFirst(salt, username)
+	if err != nil {
+		t.Fatalf("client failed to produce its proof first: %s", err)
+	}
+	if _, err := server.M(salt, username); err != nil {
+		t.Fatalf("server failed to produce M: %s", err)
+	}
+	if !server.GoodClientProof(proof) {
+		t.Fatal("server rejected client proof")
+	}
+	M, err := server.M(salt, username)
+	if err != nil {
+		t.Fatalf("server failed to produce M: %s", err)
+	}
+	if !client.GoodServerProof(salt, username, M) {
+		t.Error("client rejected server proof")
+	}
+	if _, err := server.ClientProofFirst(salt, username); err == nil {
+		t.Error("server produced a client proof")
+	}
+}
+
 // These copy utilities should probably be moved elsewhere. And perhaps they are
 // unnecessary. For for future tests, I will want to modify the client or the server
 // SRP object on its own, without changing the values in the other.
@@ -94,6 +129,9 @@ func (s *SRP) copy(x *SRP) *SRP {
 	s.isServer = x.isServer
 	s.badState = x.badState
 	s.isServerProved = x.isServerProved
+	s.isClientProved = x.isClientProved
+	s.credChanged = x.credChanged
+	s.isFake = x.isFake
 	s.m = x.m
 	s.cProof = x.cProof
 
@@ -0,0 +1,292 @@
+package srp
+
+import (
+	"context"
+	"crypto/hmac"
+	rand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+/*
+Starting a handshake costs the server an exponentiation for B, and costs the
+client nothing, so a flood of hellos can keep a server busy. A PuzzleGate makes
+clients pay first when the server is loaded. It hands out hashcash style
+challenges, and a client must find a counter such that
+
+	SHA-256(challenge | data | counter)
+
+starts with as many zero bits as the challenge demands, where data is what the
+client is about to send (its identity and A, say) and counter is a big endian
+uint64. That takes the client about 2^difficulty hashes and the server one to
+check. The difficulty rises with the number of pending handshakes.
+
+A challenge is
+
+	version (1 byte) | difficulty (1 byte) | expiry (8 bytes) | nonce (16 bytes) | HMAC-SHA256 (32 bytes)
+
+so the gate doesn't have to remember the challenges it hands out, only the ones
+that have been used, which it refuses to accept again.
+*/
+
+const (
+	puzzleVersion   = 1
+	puzzleNonceSize = 16
+	puzzleSize      = 2 + 8 + puzzleNonceSize + sha256.Size
+
+	// MaxPuzzleDifficulty is the most a PuzzleGate may ask for.
+	MaxPuzzleDifficulty = 32
+)
+
+// Defaults for PuzzleConfig.
+const (
+	DefaultPuzzleDifficulty = 16
+	DefaultPuzzleTTL        = 30 * time.Second
+)
+
+// Errors returned by PuzzleGate.Check.
+var (
+	ErrPuzzleRequired = errors.New("puzzle required")
+	ErrBadPuzzle      = errors.New("bad puzzle solution")
+)
+
+/*
+PuzzleConfig configures a PuzzleGate.
+
+Puzzles are required once Load returns at least Threshold. The difficulty is
+then BaseDifficulty bits, plus one for every Step more, up to MaxDifficulty.
+*/
+type PuzzleConfig struct {
+	// Key authenticates challenges. Gates sharing a key accept each other's
+	// challenges, but each refuses only its own used ones. Nil means a random key.
+	Key []byte
+
+	// Load returns the number of pending handshakes, as SessionManager.Len does.
+	Load func() int
+
+	Threshold      int // zero means puzzles are always required
+	BaseDifficulty int // zero means DefaultPuzzleDifficulty
+	MaxDifficulty  int // zero means MaxPuzzleDifficulty
+	Step           int // zero means Threshold, or 1 if that is zero
+
+	// TTL is how long a challenge may be used for. Zero means DefaultPuzzleTTL.
+	TTL time.Duration
+
+	// Now returns the current time. Nil means time.Now. It is there for tests.
+	Now func() time.Time
+}
+
+// PuzzleGate hands out and checks puzzles. It is safe for concurrent use.
+type PuzzleGate struct {
+	cfg PuzzleConfig
+
+	mu      sync.Mutex
+	used    map[[puzzleNonceSize]byte]time.Time // nonce to expiry
+	sweepAt int
+}
+
+// NewPuzzleGate returns a PuzzleGate. cfg.Load is required.
+func NewPuzzleGate(cfg *PuzzleConfig) (*PuzzleGate, error) {
+	if cfg == nil || cfg.Load == nil {
+		return nil, fmt.Errorf("puzzle gate needs a load function")
+	}
+	c := *cfg
+	if c.Key == nil {
+		c.Key = make([]byte, 32)
+		if _, err := rand.Read(c.Key); err != nil {
+			// If we can't get random bytes from the system, then we have no business doing anything crypto related.
+			panic(fmt.Sprintf("Failed to get random bytes: %v", err))
+		}
+	}
+	if len(c.Key) < 32 {
+		return nil, fmt.Errorf("puzzle key must be at least 32 bytes")
+	}
+	if c.BaseDifficulty == 0 {
+		c.BaseDifficulty = DefaultPuzzleDifficulty
+	}
+	if c.MaxDifficulty == 0 {
+		c.MaxDifficulty = MaxPuzzleDifficulty
+	}
+	if c.BaseDifficulty < 1 || c.MaxDifficulty < c.BaseDifficulty || c.MaxDifficulty > MaxPuzzleDifficulty {
+		return nil, fmt.Errorf("puzzle difficulties must satisfy 1 <= base <= max <= %d", MaxPuzzleDifficulty)
+	}
+	if c.Step <= 0 {
+		c.Step = c.Threshold
+	}
+	if c.Step <= 0 {
+		c.Step = 1
+	}
+	if c.TTL <= 0 {
+		c.TTL = DefaultPuzzleTTL
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return &PuzzleGate{cfg: c, used: make(map[[puzzleNonceSize]byte]time.Time), sweepAt: 1024}, nil
+}
+
+// Difficulty returns the number of zero bits puzzles need now, or zero if none are needed.
+func (g *PuzzleGate) Difficulty() int {
+	load := g.cfg.Load()
+	if load < g.cfg.Threshold {
+		return 0
+	}
+	d := g.cfg.BaseDifficulty + (load-g.cfg.Threshold)/g.cfg.Step
+	if d > g.cfg.MaxDifficulty {
+		d = g.cfg.MaxDifficulty
+	}
+	return d
+}
+
+// Challenge returns a new challenge at the current difficulty, or nil if no puzzle is needed.
+func (g *PuzzleGate) Challenge() []byte {
+	d := g.Difficulty()
+	if d == 0 {
+		return nil
+	}
+	c := make([]byte, puzzleSize-sha256.Size, puzzleSize)
+	c[0], c[1] = puzzleVersion, byte(d)
+	binary.BigEndian.PutUint64(c[2:10], uint64(g.cfg.Now().Add(g.cfg.TTL).Unix()))
+	if _, err := rand.Read(c[10:]); err != nil {
+		// If we can't get random bytes from the system, then we have no business doing anything crypto related.
+		panic(fmt.Sprintf("Failed to get random bytes: %v", err))
+	}
+	return append(c, g.mac(c)...)
+}
+
+/*
+Check decides whether a handshake may go ahead. challenge and counter are the
+client's solution for data, or nil and zero if it hasn't offered one.
+
+With no solution, Check returns nil if no puzzle is needed and ErrPuzzleRequired
+if one is, and the caller should hand the client a Challenge. A solution is
+checked even if no puzzle is needed; Check returns ErrBadPuzzle if it is wrong,
+for other data, expired or already used.
+*/
+func (g *PuzzleGate) Check(challenge, data []byte, counter uint64) error {
+	if challenge == nil {
+		if g.Difficulty() > 0 {
+			return ErrPuzzleRequired
+		}
+		return nil
+	}
+	if len(challenge) != puzzleSize || challenge[0] != puzzleVersion {
+		return ErrBadPuzzle
+	}
+	body := challenge[:puzzleSize-sha256.Size]
+	if !hmac.Equal(g.mac(body), challenge[len(body):]) {
+		return ErrBadPuzzle
+	}
+	expiry := time.Unix(int64(binary.BigEndian.Uint64(body[2:10])), 0)
+	now := g.cfg.Now()
+	if !now.Before(expiry) {
+		return ErrBadPuzzle
+	}
+	if puzzleZeroBits(challenge, data, counter) < int(body[1]) {
+		return ErrBadPuzzle
+	}
+
+	var nonce [puzzleNonceSize]byte
+	copy(nonce[:], body[10:])
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.used[nonce]; ok {
+		return ErrBadPuzzle
+	}
+	if len(g.used) >= g.sweepAt {
+		for n, exp := range g.used {
+			if !now.Before(exp) {
+				delete(g.used, n)
+			}
+		}
+		// Sweeping is linear, so don't do it again until the map has doubled.
+		g.sweepAt = 2 * len(g.used)
+		if g.sweepAt < 1024 {
+			g.sweepAt = 1024
+		}
+	}
+	g.used[nonce] = expiry
+	return nil
+}
+
+func (g *PuzzleGate) mac(body []byte) []byte {
+	h := hmac.New(sha256.New, g.cfg.Key)
+	_, _ = h.Write([]byte("SRP puzzle v1"))
+	_, _ = h.Write(body)
+	return h.Sum(nil)
+}
+
+// PuzzleDifficulty returns the number of zero bits challenge asks for.
+func PuzzleDifficulty(challenge []byte) (int, error) {
+	if len(challenge) != puzzleSize || challenge[0] != puzzleVersion {
+		return 0, fmt.Errorf("malformed puzzle")
+	}
+	if challenge[1] > MaxPuzzleDifficulty {
+		return 0, fmt.Errorf("puzzle difficulty %d is too high", challenge[1])
+	}
+	return int(challenge[1]), nil
+}
+
+// SolvePuzzle finds the counter that solves challenge for data.
+// It gives up with ctx's error when ctx is done.
+func SolvePuzzle(ctx context.Context, challenge, data []byte) (uint64, error) {
+	d, err := PuzzleDifficulty(challenge)
+	if err != nil {
+		return 0, err
+	}
+	for counter := uint64(0); ; counter++ {
+		if counter%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+		}
+		if puzzleZeroBits(challenge, data, counter) >= d {
+			return counter, nil
+		}
+	}
+}
+
+// HelloPuzzleData returns the data a puzzle solution binds a hello to:
+// the identity and A the client sends.
+//
+//nolint:gocritic // A != a. Case matters
+func HelloPuzzleData(identity string, A *big.Int) []byte {
+	data := appendUint32(nil, uint32(len(identity)))
+	data = append(data, identity...)
+	if A != nil {
+		data = append(data, A.Bytes()...)
+	}
+	return data
+}
+
+// puzzleZeroBits returns the number of leading zero bits of the hash of a solution.
+func puzzleZeroBits(challenge, data []byte, counter uint64) int {
+	h := sha256.New()
+	_, _ = h.Write(challenge)
+	_, _ = h.Write(data)
+	var c [8]byte
+	binary.BigEndian.PutUint64(c[:], counter)
+	_, _ = h.Write(c[:])
+	sum := h.Sum(nil)
+
+	n := 0
+	for i := 0; i+8 <= len(sum); i += 8 {
+		word := binary.BigEndian.Uint64(sum[i:])
+		n += bits.LeadingZeros64(word)
+		if word != 0 {
+			break
+		}
+	}
+	return n
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,99 @@
+package srp
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestPuzzleGate(t *testing.T) {
+	ctx := context.Background()
+	load := 0
+	now := time.Unix(1600000000, 0)
+	g, err := NewPuzzleGate(&PuzzleConfig{
+		Load:           func() int { return load },
+		Threshold:      10,
+		BaseDifficulty: 4,
+		MaxDifficulty:  8,
+		Step:           5,
+		TTL:            time.Minute,
+		Now:            func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct{ load, difficulty int }{{0, 0}, {9, 0}, {10, 4}, {14, 4}, {15, 5}, {100, 8}} {
+		load = tc.load
+		if d := g.Difficulty(); d != tc.difficulty {
+			t.Errorf("difficulty %d at load %d, want %d", d, tc.load, tc.difficulty)
+		}
+	}
+
+	load = 0
+	if g.Challenge() != nil {
+		t.Error("challenge without load")
+	}
+	if err := g.Check(nil, nil, 0); err != nil {
+		t.Errorf("puzzle required without load: %v", err)
+	}
+
+	load = 20
+	if err := g.Check(nil, nil, 0); !errors.Is(err, ErrPuzzleRequired) {
+		t.Errorf("expected ErrPuzzleRequired, got %v", err)
+	}
+	challenge := g.Challenge()
+	if d, err := PuzzleDifficulty(challenge); err != nil || d != 6 {
+		t.Fatalf("challenge of difficulty %d: %v", d, err)
+	}
+	data := HelloPuzzleData("alice", big.NewInt(12345))
+	counter, err := SolvePuzzle(ctx, challenge, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if puzzleZeroBits(challenge, data, counter) < 6 {
+		t.Fatal("SolvePuzzle returned a bad solution")
+	}
+
+	if err := g.Check(challenge, HelloPuzzleData("alice", big.NewInt(12346)), counter); !errors.Is(err, ErrBadPuzzle) {
+		t.Errorf("solution accepted for other data: %v", err)
+	}
+	tampered := append([]byte{}, challenge...)
+	tampered[1] = 1 // easier
+	if err := g.Check(tampered, data, counter); !errors.Is(err, ErrBadPuzzle) {
+		t.Errorf("tampered challenge accepted: %v", err)
+	}
+	if err := g.Check(challenge, data, counter); err != nil {
+		t.Errorf("good solution rejected: %v", err)
+	}
+	if err := g.Check(challenge, data, counter); !errors.Is(err, ErrBadPuzzle) {
+		t.Errorf("solution accepted twice: %v", err)
+	}
+
+	challenge = g.Challenge()
+	counter, _ = SolvePuzzle(ctx, challenge, data)
+	now = now.Add(time.Minute)
+	if err := g.Check(challenge, data, counter); !errors.Is(err, ErrBadPuzzle) {
+		t.Errorf("expired solution accepted: %v", err)
+	}
+
+	other, _ := NewPuzzleGate(&PuzzleConfig{Load: func() int { return 1 }})
+	if err := other.Check(g.Challenge(), data, counter); !errors.Is(err, ErrBadPuzzle) {
+		t.Errorf("challenge accepted under another key: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	hard := append([]byte{}, g.Challenge()...)
+	hard[1] = MaxPuzzleDifficulty
+	if _, err := SolvePuzzle(cancelled, hard, data); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
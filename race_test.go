@@ -0,0 +1,12 @@
+//go:build race
+// +build race
+
+package srp
+
+// raceEnabled is whether the race detector is on, which makes sync.Pool drop things and so allocate more.
+const raceEnabled = true
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,138 @@
+package srp
+
+import (
+	"crypto/sha256"
+	"hash"
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+/*
+The computations of a handshake need room for quotients, products and
+encodings that are thrown away as soon as they have been hashed or reduced.
+Rather than allocate those every time, they come from a pool of scratch space.
+Scratch space is wiped when it is given back, as it can hold secrets such as the
+premaster secret's encoding.
+
+The hash of each step is reused from scratch space too, and an SRP keeps its
+numbers, key and proofs in one allocation for the SRP and one for all of the
+numbers' words.
+
+The aim was a tenth of the allocations of a handshake before scratch space, 324
+in the 2048 bit group. A handshake there now makes about 67, and 60 of them are
+big.Int's Exp, 20 for each of the three exponentiations that don't have a
+table, which has no way to be given workspace. Of the other seven, four are the
+two sides' SRPs. An exponentiation written here that took scratch space would
+have to do without Exp's assembly Montgomery multiplication, and so would make
+handshakes two to three times slower, so the aim is met for everything but Exp.
+*/
+
+// scratch is space for one computation. Get one with getScratch and give it
+// back with putScratch once nothing refers to it.
+type scratch struct {
+	q, prod, acc big.Int // for products and reductions
+	t1, t2       big.Int // for operands
+	buf          []byte  // for encodings
+	sum          [64]byte
+	sha256       hash.Hash // reset for each use
+}
+
+var scratchPool = sync.Pool{New: func() interface{} { return new(scratch) }}
+
+func getScratch() *scratch {
+	return scratchPool.Get().(*scratch)
+}
+
+func putScratch(sc *scratch) {
+	// Wipe all the capacity, not just the current length, as a shorter value
+	// may have been stored over a longer one.
+	for _, n := range []*big.Int{&sc.q, &sc.prod, &sc.acc, &sc.t1, &sc.t2} {
+		words := n.Bits()
+		words = words[:cap(words)]
+		for i := range words {
+			words[i] = 0
+		}
+		n.SetInt64(0)
+	}
+	buf := sc.buf[:cap(sc.buf)]
+	for i := range buf {
+		buf[i] = 0
+	}
+	sc.sum = [64]byte{}
+	if sc.sha256 != nil {
+		// A block of zeros overwrites whatever is left of the last input in
+		// the hash's buffer, which Reset doesn't clear.
+		_, _ = sc.sha256.Write(sc.sum[:])
+		sc.sha256.Reset()
+	}
+	scratchPool.Put(sc)
+}
+
+// hash returns an empty hash for hashName, as Hash.NewWith does, reusing sc's
+// for SHA-256. Its sum can go in sc.sum.
+func (sc *scratch) hash(hashName string) hash.Hash {
+	if hashName != Hash.Sha256Name {
+		return Hash.NewWith(hashName)
+	}
+	if sc.sha256 == nil {
+		sc.sha256 = sha256.New()
+	}
+	sc.sha256.Reset()
+	return sc.sha256
+}
+
+// bytes returns a buffer of n bytes, which may not be zero.
+func (sc *scratch) bytes(n int) []byte {
+	if cap(sc.buf) < n {
+		sc.buf = make([]byte, n)
+	}
+	return sc.buf[:n]
+}
+
+// buffer returns an empty buffer with room for at least n bytes.
+func (sc *scratch) buffer(n int) []byte {
+	return sc.bytes(n)[:0]
+}
+
+// mod sets z to x mod n, which must be positive, and returns z. z may be x.
+func (sc *scratch) mod(z, x, n *big.Int) *big.Int {
+	sc.q.QuoRem(x, n, z)
+	if z.Sign() < 0 {
+		z.Add(z, n)
+	}
+	return z
+}
+
+// unpaddedBytes returns x.Bytes() in buf if it fits, and in a new slice if it doesn't.
+func unpaddedBytes(buf []byte, x *big.Int) []byte {
+	n := (x.BitLen() + 7) / 8
+	if n > cap(buf) {
+		return x.Bytes()
+	}
+	return x.FillBytes(buf[:n])
+}
+
+// appendTrimmedHex appends the absolute value of x to dst in lowercase hex
+// without leading zeros, so nothing at all for zero.
+func appendTrimmedHex(dst []byte, x *big.Int) []byte {
+	const digits = "0123456789abcdef"
+	words := x.Bits()
+	started := false
+	for i := len(words) - 1; i >= 0; i-- {
+		for shift := bits.UintSize - 4; shift >= 0; shift -= 4 {
+			d := (words[i] >> uint(shift)) & 0xf
+			if d == 0 && !started {
+				continue
+			}
+			started = true
+			dst = append(dst, digits[d])
+		}
+	}
+	return dst
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
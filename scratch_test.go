@@ -0,0 +1,64 @@
+package srp
+
+import (
+	rand "crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestAppendTrimmedHex(t *testing.T) {
+	nums := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(0xf), big.NewInt(0x10), big.NewInt(-0xabc)}
+	for i := 0; i < 20; i++ {
+		n, err := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, uint(8*i+3)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nums = append(nums, n)
+	}
+	for _, n := range nums {
+		want := strings.TrimLeft(fmt.Sprintf("%x", new(big.Int).Abs(n)), "0")
+		if got := string(appendTrimmedHex([]byte("prefix"), n)); got != "prefix"+want {
+			t.Errorf("%x: got %q, want %q", n, got, "prefix"+want)
+		}
+		if got := serverStyleHexFromBigInt(n); got != want {
+			t.Errorf("%x: serverStyleHexFromBigInt gave %q", n, got)
+		}
+	}
+}
+
+func TestScratchWiped(t *testing.T) {
+	sc := getScratch()
+	buf := sc.bytes(64)
+	for i := range buf {
+		buf[i] = 0xff
+	}
+	sc.acc.SetBytes(buf)
+	words := sc.acc.Bits()
+	// A shorter value leaves the rest of the longer one behind its length.
+	sc.acc.SetInt64(1)
+	sum := &sc.sum
+	for i := range sum {
+		sum[i] = 0xff
+	}
+	putScratch(sc)
+	if *sum != [64]byte{} {
+		t.Fatal("scratch sum wasn't wiped")
+	}
+	for _, b := range buf {
+		if b != 0 {
+			t.Fatal("scratch buffer wasn't wiped")
+		}
+	}
+	for _, w := range words {
+		if w != 0 {
+			t.Fatal("scratch number wasn't wiped")
+		}
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,191 @@
+package srp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	rand "crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+/*
+A captured v can be used to impersonate the server and to mount an offline
+password cracking attack. VerifierSealer lets a server keep verifiers
+encrypted at rest under a key that does not live in the same place as the
+verifier records (a "pepper").
+
+A sealed verifier is laid out as
+
+	version (1 byte) | len(keyID) (1 byte) | keyID | nonce (12 bytes) | AES-256-GCM ciphertext
+
+The header and the identity are both included as associated data, so a sealed
+verifier can't be moved from one identity to another or relabeled with a
+different key ID.
+*/
+
+const (
+	sealFormatVersion = 1
+	sealKeySize       = 32
+)
+
+// ErrUnknownSealingKey is returned when opening a verifier sealed under
+// a key ID that the VerifierSealer was not given.
+var ErrUnknownSealingKey = errors.New("verifier sealed with unknown key")
+
+// VerifierSealer seals verifiers with an AEAD under server held keys.
+// New verifiers are sealed under the current key, while verifiers sealed
+// under any of its keys can be opened. That allows keys to be rotated.
+type VerifierSealer struct {
+	currentKeyID string
+	aeads        map[string]cipher.AEAD
+}
+
+// NewVerifierSealer creates a VerifierSealer from a map of key IDs to 32 byte keys.
+// currentKeyID names the key used for sealing and must be in keys.
+func NewVerifierSealer(currentKeyID string, keys map[string][]byte) (*VerifierSealer, error) {
+	if _, ok := keys[currentKeyID]; !ok {
+		return nil, fmt.Errorf("current key %q not among keys", currentKeyID)
+	}
+	aeads, err := newKeyedAEADs(keys)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifierSealer{currentKeyID: currentKeyID, aeads: aeads}, nil
+}
+
+// newKeyedAEADs sets up AES-256-GCM for each of a map of key IDs to keys.
+func newKeyedAEADs(keys map[string][]byte) (map[string]cipher.AEAD, error) {
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("key ID must be between 1 and 255 bytes long")
+		}
+		if len(key) != sealKeySize {
+			return nil, fmt.Errorf("key %q is %d bytes instead of %d", id, len(key), sealKeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up cipher for key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up GCM for key %q: %w", id, err)
+		}
+		aeads[id] = aead
+	}
+	return aeads, nil
+}
+
+// CurrentKeyID returns the ID of the key used for sealing.
+func (vs *VerifierSealer) CurrentKeyID() string {
+	return vs.currentKeyID
+}
+
+// Seal encrypts v for identity under the current key.
+func (vs *VerifierSealer) Seal(identity string, v *big.Int) ([]byte, error) {
+	if v == nil || v.Sign() < 1 {
+		return nil, fmt.Errorf("no verifier to seal")
+	}
+	aead := vs.aeads[vs.currentKeyID]
+
+	header := []byte{sealFormatVersion, byte(len(vs.currentKeyID))}
+	header = append(header, vs.currentKeyID...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// If we can't get random bytes from the system, then we have no business doing anything crypto related.
+		panic(fmt.Sprintf("Failed to get random bytes: %v", err))
+	}
+
+	sealed := make([]byte, 0, len(header)+len(nonce)+len(v.Bytes())+aead.Overhead())
+	sealed = append(sealed, header...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, v.Bytes(), sealAD(header, identity)), nil
+}
+
+// Open decrypts a verifier sealed for identity.
+func (vs *VerifierSealer) Open(identity string, sealed []byte) (*big.Int, error) {
+	keyID, err := SealedKeyID(sealed)
+	if err != nil {
+		return nil, err
+	}
+	aead, ok := vs.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSealingKey, keyID)
+	}
+
+	headerLen := 2 + len(keyID)
+	if len(sealed) < headerLen+aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("sealed verifier too short")
+	}
+	header := sealed[:headerLen]
+	nonce := sealed[headerLen : headerLen+aead.NonceSize()]
+	ciphertext := sealed[headerLen+aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, sealAD(header, identity))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sealed verifier: %w", err)
+	}
+	return new(big.Int).SetBytes(plaintext), nil
+}
+
+// SealedKeyID returns the ID of the key that sealed was sealed under.
+func SealedKeyID(sealed []byte) (string, error) {
+	if len(sealed) < 2 {
+		return "", fmt.Errorf("sealed verifier too short")
+	}
+	if sealed[0] != sealFormatVersion {
+		return "", fmt.Errorf("unknown sealed verifier format %d", sealed[0])
+	}
+	idLen := int(sealed[1])
+	if len(sealed) < 2+idLen {
+		return "", fmt.Errorf("sealed verifier too short")
+	}
+	return string(sealed[2 : 2+idLen]), nil
+}
+
+func sealAD(header []byte, identity string) []byte {
+	ad := make([]byte, 0, len(header)+len(identity))
+	ad = append(ad, header...)
+	return append(ad, identity...)
+}
+
+// SealRecord replaces rec.Verifier with a sealed copy in rec.SealedVerifier.
+func (vs *VerifierSealer) SealRecord(rec *VerifierRecord) error {
+	name, err := credentialName(rec.Identity, rec.Credential)
+	if err != nil {
+		return err
+	}
+	sealed, err := vs.Seal(name, rec.Verifier)
+	if err != nil {
+		return err
+	}
+	rec.SealedVerifier = sealed
+	rec.Verifier = nil
+	return nil
+}
+
+// OpenRecord sets rec.Verifier from rec.SealedVerifier and clears the latter.
+// A record that isn't sealed is left as it is.
+func (vs *VerifierSealer) OpenRecord(rec *VerifierRecord) error {
+	if rec.SealedVerifier == nil {
+		return nil
+	}
+	name, err := credentialName(rec.Identity, rec.Credential)
+	if err != nil {
+		return err
+	}
+	v, err := vs.Open(name, rec.SealedVerifier)
+	if err != nil {
+		return err
+	}
+	rec.Verifier = v
+	rec.SealedVerifier = nil
+	return nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
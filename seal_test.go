@@ -0,0 +1,126 @@
+package srp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func testSealer(t *testing.T, current string, ids ...string) *VerifierSealer {
+	t.Helper()
+	keys := make(map[string][]byte)
+	for i, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, sealKeySize)
+	}
+	vs, err := NewVerifierSealer(current, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vs
+}
+
+func TestSealOpen(t *testing.T) {
+	vs := testSealer(t, "k1", "k1")
+	v := NumberFromString("0x7E273DE8696FFC4F4E337D05B4B375BEB0DDE1569E8FA00A")
+
+	sealed, err := vs.Seal("alice", v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, v.Bytes()) {
+		t.Error("sealed verifier contains plaintext verifier")
+	}
+	if id, _ := SealedKeyID(sealed); id != "k1" {
+		t.Errorf("sealed under key %q", id)
+	}
+
+	opened, err := vs.Open("alice", sealed)
+	if err != nil {
+		t.Fatalf("failed to open: %s", err)
+	}
+	if opened.Cmp(v) != 0 {
+		t.Error("opened verifier doesn't match")
+	}
+
+	if _, err := vs.Open("bob", sealed); err == nil {
+		t.Error("opened verifier sealed for a different identity")
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := vs.Open("alice", tampered); err == nil {
+		t.Error("opened tampered verifier")
+	}
+
+	other := testSealer(t, "k2", "k2")
+	if _, err := other.Open("alice", sealed); !errors.Is(err, ErrUnknownSealingKey) {
+		t.Errorf("expected ErrUnknownSealingKey, got %v", err)
+	}
+}
+
+func TestNewVerifierSealerBadKeys(t *testing.T) {
+	if _, err := NewVerifierSealer("k1", map[string][]byte{"k2": make([]byte, 32)}); err == nil {
+		t.Error("accepted missing current key")
+	}
+	if _, err := NewVerifierSealer("k1", map[string][]byte{"k1": make([]byte, 16)}); err == nil {
+		t.Error("accepted short key")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	ctx := context.Background()
+	raw := NewMemoryStore()
+	old := testSealer(t, "old", "old")
+
+	// One record sealed under the old key, one never sealed at all
+	if err := NewSealedStore(raw, old).Enroll(ctx, &VerifierRecord{
+		Identity: "alice", GroupID: RFC5054Group2048, Verifier: big.NewInt(1234),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := raw.Enroll(ctx, &VerifierRecord{
+		Identity: "bob", GroupID: RFC5054Group2048, Verifier: big.NewInt(5678),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := testSealer(t, "new", "old", "new")
+	n, err := Rewrap(ctx, raw, rotated)
+	if err != nil {
+		t.Fatalf("rewrap failed: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("rewrapped %d records instead of 2", n)
+	}
+
+	expected := map[string]int64{"alice": 1234, "bob": 5678}
+	onlyNew := testSealer(t, "new", "old", "new")
+	delete(onlyNew.aeads, "old")
+	for id, v := range expected {
+		rec, err := raw.Lookup(ctx, id, PrimaryCredential)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Verifier != nil {
+			t.Errorf("%s has plaintext verifier after rewrap", id)
+		}
+		rec, err = NewSealedStore(raw, onlyNew).Lookup(ctx, id, PrimaryCredential)
+		if err != nil {
+			t.Fatalf("couldn't open %s with new key: %s", id, err)
+		}
+		if rec.Verifier.Int64() != v {
+			t.Errorf("%s has wrong verifier after rewrap", id)
+		}
+	}
+
+	if n, _ := Rewrap(ctx, raw, rotated); n != 0 {
+		t.Errorf("second rewrap rewrote %d records", n)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,159 @@
+package srp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SealedStore wraps a VerifierStore so that verifiers are sealed by a
+// VerifierSealer on the way in and opened on the way out.
+// Records in the underlying store that are not sealed are returned as they are,
+// which allows an existing store to be migrated with Rewrap.
+type SealedStore struct {
+	store  VerifierStore
+	sealer *VerifierSealer
+}
+
+var (
+	_ VerifierStore  = &SealedStore{} //nolint:exhaustruct
+	_ VerifierLister = &SealedStore{} //nolint:exhaustruct
+)
+
+// NewSealedStore returns a SealedStore that keeps its records in store.
+func NewSealedStore(store VerifierStore, sealer *VerifierSealer) *SealedStore {
+	return &SealedStore{store: store, sealer: sealer}
+}
+
+// Lookup returns the record for the credential with its verifier opened.
+func (ss *SealedStore) Lookup(ctx context.Context, identity, credential string) (*VerifierRecord, error) {
+	rec, err := ss.store.Lookup(ctx, identity, credential)
+	if err != nil {
+		return nil, err
+	}
+	if err := ss.sealer.OpenRecord(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Enroll seals the verifier and enrolls the record. rec itself is not sealed.
+func (ss *SealedStore) Enroll(ctx context.Context, rec *VerifierRecord) error {
+	sealed := *rec
+	if err := ss.sealer.SealRecord(&sealed); err != nil {
+		return err
+	}
+	if err := ss.store.Enroll(ctx, &sealed); err != nil {
+		return err
+	}
+	rec.Version = sealed.Version
+	return nil
+}
+
+// Update seals the verifier and updates the record. rec itself is not sealed.
+func (ss *SealedStore) Update(ctx context.Context, rec *VerifierRecord) error {
+	sealed := *rec
+	if err := ss.sealer.SealRecord(&sealed); err != nil {
+		return err
+	}
+	if err := ss.store.Update(ctx, &sealed); err != nil {
+		return err
+	}
+	rec.Version = sealed.Version
+	return nil
+}
+
+// Identities lists the identities in the underlying store, if it can.
+func (ss *SealedStore) Identities(ctx context.Context) ([]string, error) {
+	lister, ok := ss.store.(VerifierLister)
+	if !ok {
+		return nil, fmt.Errorf("underlying store can't list identities")
+	}
+	return lister.Identities(ctx)
+}
+
+// Credentials lists the credentials of identity in the underlying store, if it can.
+func (ss *SealedStore) Credentials(ctx context.Context, identity string) ([]string, error) {
+	lister, ok := ss.store.(VerifierLister)
+	if !ok {
+		return nil, fmt.Errorf("underlying store can't list credentials")
+	}
+	return lister.Credentials(ctx, identity)
+}
+
+/*
+Rewrap seals every record in store under sealer's current key.
+Records that are sealed under some other key are opened and resealed, and
+records that are not sealed at all are sealed. Verifiers only ever exist
+in plaintext in memory. It returns the number of records that were rewritten.
+
+store must be the raw store (not a SealedStore) and must implement VerifierLister.
+Rewrap can run while the store is in use, as updates are made with the usual
+version check.
+*/
+func Rewrap(ctx context.Context, store VerifierStore, sealer *VerifierSealer) (int, error) {
+	lister, ok := store.(VerifierLister)
+	if !ok {
+		return 0, fmt.Errorf("store can't list identities")
+	}
+	identities, err := lister.Identities(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	count := 0
+	for _, identity := range identities {
+		credentials, err := lister.Credentials(ctx, identity)
+		if err != nil {
+			return count, fmt.Errorf("failed to list credentials of %q: %w", identity, err)
+		}
+		for _, credential := range credentials {
+			rewritten, err := rewrapOne(ctx, store, sealer, identity, credential)
+			if err != nil {
+				return count, fmt.Errorf("failed to rewrap %q credential %q: %w", identity, credential, err)
+			}
+			if rewritten {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func rewrapOne(ctx context.Context, store VerifierStore, sealer *VerifierSealer, identity, credential string) (bool, error) {
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		rec, err := store.Lookup(ctx, identity, credential)
+		if errors.Is(err, ErrUnknownIdentity) {
+			return false, nil // Removed since we listed it
+		}
+		if err != nil {
+			return false, err
+		}
+		if rec.SealedVerifier != nil {
+			keyID, err := SealedKeyID(rec.SealedVerifier)
+			if err != nil {
+				return false, err
+			}
+			if keyID == sealer.CurrentKeyID() {
+				return false, nil
+			}
+		}
+		if err := sealer.OpenRecord(rec); err != nil {
+			return false, err
+		}
+		if err := sealer.SealRecord(rec); err != nil {
+			return false, err
+		}
+		err = store.Update(ctx, rec)
+		if errors.Is(err, ErrVersionConflict) {
+			continue
+		}
+		return err == nil, err
+	}
+	return false, ErrVersionConflict
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
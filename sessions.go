@@ -0,0 +1,259 @@
+package srp
+
+import (
+	rand "crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+A server's SRP should be used for one attempt at a handshake and then thrown
+away. If it is kept, anyone holding its session ID can keep offering client
+proofs against the same b, and if it is kept for long, its b is exposed for
+longer. SessionManager holds the servers of pending handshakes in memory under
+random session IDs and enforces that: a session is removed when its proof is
+checked, whether or not the proof is good, and when its TTL runs out.
+
+A typical server calls Start with the record it is using once it has sent B,
+and Verify when the client's proof arrives. The client proves itself first, and
+its proof covers M, so Verify computes M from the record's salt and identity
+before checking the proof, and returns it as the server's proof to send back.
+*/
+
+// Defaults for SessionConfig.
+const (
+	DefaultSessionTTL            = 30 * time.Second
+	DefaultMaxPendingPerIdentity = 5
+)
+
+// Errors returned by SessionManager.
+var (
+	ErrNoSession      = errors.New("no such pending handshake")
+	ErrTooManyPending = errors.New("too many pending handshakes for identity")
+	ErrBadClientProof = errors.New("bad client proof")
+)
+
+// SessionConfig configures a SessionManager. The zero SessionConfig uses the defaults.
+type SessionConfig struct {
+	// TTL is how long a handshake may stay pending. Zero means DefaultSessionTTL.
+	TTL time.Duration
+
+	// MaxPendingPerIdentity caps the handshakes pending at once for each identity.
+	// Zero means DefaultMaxPendingPerIdentity.
+	MaxPendingPerIdentity int
+
+	// Now returns the current time. Nil means time.Now. It is there for tests.
+	Now func() time.Time
+}
+
+// PendingHandshake is a handshake held by a SessionManager.
+type PendingHandshake struct {
+	ID      string
+	Record  *VerifierRecord // the record Server was created from, real or fake
+	Server  *SRP
+	Expires time.Time
+}
+
+// SessionManager holds the servers of pending handshakes. It is safe for concurrent use.
+type SessionManager struct {
+	mu         sync.Mutex
+	cfg        SessionConfig
+	sessions   map[string]*PendingHandshake
+	byIdentity map[string]map[string]struct{}
+	sweepAt    int // sweep expired sessions once there are this many
+}
+
+// NewSessionManager returns an empty SessionManager. cfg may be nil.
+func NewSessionManager(cfg *SessionConfig) *SessionManager {
+	sm := &SessionManager{
+		sessions:   make(map[string]*PendingHandshake),
+		byIdentity: make(map[string]map[string]struct{}),
+		sweepAt:    64,
+	}
+	if cfg != nil {
+		sm.cfg = *cfg
+	}
+	if sm.cfg.TTL <= 0 {
+		sm.cfg.TTL = DefaultSessionTTL
+	}
+	if sm.cfg.MaxPendingPerIdentity <= 0 {
+		sm.cfg.MaxPendingPerIdentity = DefaultMaxPendingPerIdentity
+	}
+	if sm.cfg.Now == nil {
+		sm.cfg.Now = time.Now
+	}
+	return sm
+}
+
+// Start adds a pending handshake for rec with server, which must be a server
+// created from rec, and returns it with a new session ID. It returns
+// ErrTooManyPending if rec's identity already has the maximum number of
+// handshakes pending.
+func (sm *SessionManager) Start(rec *VerifierRecord, server *SRP) (*PendingHandshake, error) {
+	if rec == nil {
+		return nil, fmt.Errorf("a pending handshake needs a record")
+	}
+	identity := rec.Identity
+	if server == nil || !server.isServer {
+		return nil, fmt.Errorf("only a server's handshake can be pending")
+	}
+	if server.isClientProved {
+		return nil, fmt.Errorf("handshake is already complete")
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	now := sm.cfg.Now()
+	if len(sm.sessions) >= sm.sweepAt {
+		for id, p := range sm.sessions {
+			if !now.Before(p.Expires) {
+				sm.remove(id)
+			}
+		}
+		// Sweeping is linear, so don't do it again until the map has doubled.
+		sm.sweepAt = 2 * len(sm.sessions)
+		if sm.sweepAt < 64 {
+			sm.sweepAt = 64
+		}
+	}
+	for id := range sm.byIdentity[identity] {
+		if !now.Before(sm.sessions[id].Expires) {
+			sm.remove(id)
+		}
+	}
+	if len(sm.byIdentity[identity]) >= sm.cfg.MaxPendingPerIdentity {
+		return nil, ErrTooManyPending
+	}
+
+	p := &PendingHandshake{
+		ID:      NewSessionID(),
+		Record:  rec,
+		Server:  server,
+		Expires: now.Add(sm.cfg.TTL),
+	}
+	sm.sessions[p.ID] = p
+	ids := sm.byIdentity[identity]
+	if ids == nil {
+		ids = make(map[string]struct{})
+		sm.byIdentity[identity] = ids
+	}
+	ids[p.ID] = struct{}{}
+	return p, nil
+}
+
+/*
+Verify removes the pending handshake id and checks proof against its server.
+If the proof is good it returns the handshake and the server's proof, M, to send
+back; if it isn't it returns ErrBadClientProof. Either way the handshake is gone,
+so a second Verify for the same id returns ErrNoSession, as does one for an
+unknown or expired id.
+
+It is Take followed by CheckProof, for callers with nothing to do in between.
+*/
+func (sm *SessionManager) Verify(id string, proof []byte) (*PendingHandshake, []byte, error) {
+	p, err := sm.Take(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := p.CheckProof(proof)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, m, nil
+}
+
+// CheckProof checks the client's proof for p, computing M first since the proof
+// covers it, and returns M as the server's proof. It returns ErrBadClientProof
+// if the proof is wrong, or the record is fake.
+func (p *PendingHandshake) CheckProof(proof []byte) ([]byte, error) {
+	m, err := p.Server.M(p.Record.Salt, p.Record.Identity)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Server.GoodClientProof(proof) {
+		return nil, ErrBadClientProof
+	}
+	return m, nil
+}
+
+// Cancel removes the pending handshake id, if there is one.
+func (sm *SessionManager) Cancel(id string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.remove(id)
+}
+
+// Pending returns the number of handshakes pending for identity.
+func (sm *SessionManager) Pending(identity string) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	now := sm.cfg.Now()
+	n := 0
+	for id := range sm.byIdentity[identity] {
+		if now.Before(sm.sessions[id].Expires) {
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of handshakes pending for all identities.
+// It may count some that have expired but not yet been removed.
+func (sm *SessionManager) Len() int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return len(sm.sessions)
+}
+
+// Take removes and returns the pending handshake id, or ErrNoSession if there
+// isn't one or it has expired. Of any callers taking the same id at once, only
+// one gets the handshake. The caller must then check the client's proof with
+// CheckProof or throw the handshake away.
+func (sm *SessionManager) Take(id string) (*PendingHandshake, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	p, ok := sm.sessions[id]
+	if !ok {
+		return nil, ErrNoSession
+	}
+	sm.remove(id)
+	if !sm.cfg.Now().Before(p.Expires) {
+		return nil, ErrNoSession
+	}
+	return p, nil
+}
+
+// remove forgets the pending handshake id. sm.mu must be held.
+func (sm *SessionManager) remove(id string) {
+	p, ok := sm.sessions[id]
+	if !ok {
+		return
+	}
+	delete(sm.sessions, id)
+	ids := sm.byIdentity[p.Record.Identity]
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(sm.byIdentity, p.Record.Identity)
+	}
+}
+
+// sessionIDSize is the number of random bytes in a session ID.
+const sessionIDSize = 24
+
+// NewSessionID returns a new unguessable session ID, URL-safe base64 without padding.
+func NewSessionID() string {
+	b := make([]byte, sessionIDSize)
+	if _, err := rand.Read(b); err != nil {
+		// If we can't get random bytes from the system, then we have no business doing anything crypto related.
+		panic(fmt.Sprintf("Failed to get random bytes: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,117 @@
+package srp
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// pendingServer returns a record and a server for it that has sent B, and the
+// client's proof, which the client sends first.
+func pendingServer(t *testing.T, identity string) (*VerifierRecord, *SRP, []byte) {
+	t.Helper()
+	grp := KnownGroups[RFC5054Group2048]
+	x := big.NewInt(0x5eed)
+	client, server := keyedPair(t, std, grp, x, nil)
+	rec := &VerifierRecord{Identity: identity, Salt: []byte("salt"), GroupID: RFC5054Group2048, Verifier: server.v}
+	proof, err := client.ClientProofFirst(rec.Salt, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec, server, proof
+}
+
+func TestSessionManager(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	sm := NewSessionManager(&SessionConfig{
+		TTL:                   10 * time.Second,
+		MaxPendingPerIdentity: 2,
+		Now:                   func() time.Time { return now },
+	})
+
+	rec, server, proof := pendingServer(t, "alice")
+	p, err := sm.Start(rec, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.Pending("alice") != 1 {
+		t.Errorf("%d pending", sm.Pending("alice"))
+	}
+	if _, _, err := sm.Verify("nonsense", proof); !errors.Is(err, ErrNoSession) {
+		t.Errorf("expected ErrNoSession for unknown ID, got %v", err)
+	}
+	got, m, err := sm.Verify(p.ID, proof)
+	if err != nil {
+		t.Fatalf("client's proof, sent first, rejected: %s", err)
+	}
+	if got.Record != rec || got.Server != server {
+		t.Error("Verify returned the wrong handshake")
+	}
+	if want, _ := server.M(rec.Salt, rec.Identity); !bytes.Equal(m, want) {
+		t.Error("Verify returned the wrong server proof")
+	}
+	if _, _, err := sm.Verify(p.ID, proof); !errors.Is(err, ErrNoSession) {
+		t.Errorf("expected ErrNoSession on reuse, got %v", err)
+	}
+
+	// A bad proof uses the session up too.
+	rec, server, proof = pendingServer(t, "alice")
+	p, _ = sm.Start(rec, server)
+	bad := append([]byte{}, proof...)
+	bad[0] ^= 1
+	if _, _, err := sm.Verify(p.ID, bad); !errors.Is(err, ErrBadClientProof) {
+		t.Errorf("expected ErrBadClientProof, got %v", err)
+	}
+	if _, _, err := sm.Verify(p.ID, proof); !errors.Is(err, ErrNoSession) {
+		t.Errorf("expected ErrNoSession after bad proof, got %v", err)
+	}
+
+	// Expiry
+	rec, server, proof = pendingServer(t, "alice")
+	p, _ = sm.Start(rec, server)
+	now = now.Add(10 * time.Second)
+	if _, _, err := sm.Verify(p.ID, proof); !errors.Is(err, ErrNoSession) {
+		t.Errorf("expected ErrNoSession after expiry, got %v", err)
+	}
+
+	// The per identity cap, which expired sessions don't count against
+	bob, s1, _ := pendingServer(t, "bob")
+	_, s2, _ := pendingServer(t, "bob")
+	_, s3, _ := pendingServer(t, "bob")
+	carol, _, _ := pendingServer(t, "carol")
+	if _, err := sm.Start(bob, s1); err != nil {
+		t.Fatal(err)
+	}
+	p2, err := sm.Start(bob, s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.Start(bob, s3); !errors.Is(err, ErrTooManyPending) {
+		t.Errorf("expected ErrTooManyPending, got %v", err)
+	}
+	if _, err := sm.Start(carol, s3); err != nil {
+		t.Errorf("bob's pending handshakes blocked carol: %v", err)
+	}
+	sm.Cancel(p2.ID)
+	if _, err := sm.Start(bob, s3); err != nil {
+		t.Errorf("cancelled handshake still counted: %v", err)
+	}
+	now = now.Add(10 * time.Second)
+	if sm.Pending("bob") != 0 {
+		t.Errorf("%d pending after expiry", sm.Pending("bob"))
+	}
+	if _, err := sm.Start(bob, s1); err != nil {
+		t.Errorf("expired handshakes still counted: %v", err)
+	}
+
+	if _, err := sm.Start(rec, NewClientStd(KnownGroups[RFC5054Group2048], big.NewInt(1))); err == nil {
+		t.Error("started a client")
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
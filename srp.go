@@ -9,10 +9,15 @@ package srp
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
+	"math/bits"
+	"time"
 )
 
 /*
@@ -65,10 +70,18 @@ type SRP struct {
 	m                []byte // M is server proof knowledge of key
 	cProof           []byte // Client proof of knowledge of key
 	isServerProved   bool   // whether server has proved knowledge of key
+	isClientProved   bool   // whether client has proved knowledge of key
+	credChanged      bool   // whether this session has been used to change or add a credential
+	isFake           bool   // whether this server is for a fake record, so the client can never be proved
 	isServer         bool
 	badState         bool
 	hashName         string // Hash used for constructing k and u
 	stdPadding       bool   // Whether to use RFC5054 PAD for creation of k and u
+
+	// Room for the numbers and hashes above, so that a handshake doesn't
+	// allocate each of them
+	nums [8]big.Int
+	sums [3][sha256.Size]byte // key, m and cProof
 }
 
 var (
@@ -118,16 +131,14 @@ func NewServerStd(group *Group, v *big.Int) *SRP {
 
 func newSRP(isServer bool, group *Group, xORv, k *big.Int, std bool) *SRP {
 	s := &SRP{
-		// Setting these to Int-zero gives me a useful way to test
-		// if these have been properly set later
-		ephemeralPublicA: big.NewInt(0),
-		ephemeralPrivate: big.NewInt(0),
-		ephemeralPublicB: big.NewInt(0),
-		u:                big.NewInt(0),
-		k:                big.NewInt(0),
-		x:                big.NewInt(0),
-		v:                big.NewInt(0),
-		premasterKey:     big.NewInt(0),
+		ephemeralPublicA: nil,
+		ephemeralPrivate: nil,
+		ephemeralPublicB: nil,
+		u:                nil,
+		k:                nil,
+		x:                nil,
+		v:                nil,
+		premasterKey:     nil,
 		key:              nil,
 		group:            group,
 
@@ -138,7 +149,32 @@ func newSRP(isServer bool, group *Group, xORv, k *big.Int, std bool) *SRP {
 		m:              nil,
 		cProof:         nil,
 		isServerProved: false,
+		isClientProved: false,
 		stdPadding:     std,
+
+		credChanged: false,
+		isFake:      false,
+
+		nums: [8]big.Int{},
+		sums: [3][sha256.Size]byte{},
+	}
+	// Setting these to Int-zero gives me a useful way to test
+	// if these have been properly set later
+	for i, n := range []**big.Int{
+		&s.ephemeralPublicA, &s.ephemeralPrivate, &s.ephemeralPublicB, &s.u,
+		&s.k, &s.x, &s.v, &s.premasterKey,
+	} {
+		*n = &s.nums[i]
+	}
+	if group != nil && group.n != nil {
+		// One allocation for all of their words. None is longer than N,
+		// give or take a word for a sum before it is reduced, unless
+		// something odd is going on, in which case it gets its own.
+		size := (group.n.BitLen()+bits.UintSize-1)/bits.UintSize + 1
+		words := make([]big.Word, len(s.nums)*size)
+		for i := range s.nums {
+			s.nums[i].SetBits(words[i*size : i*size : (i+1)*size])
+		}
 	}
 
 	if s.isServer {
@@ -158,16 +194,25 @@ func newSRP(isServer bool, group *Group, xORv, k *big.Int, std bool) *SRP {
 		s.k.Set(k)
 	}
 
-	s.generateMySecret()
+	obs := currentObserver()
+	var start time.Time
+	if obs != nil {
+		start = time.Now()
+	}
 	if s.isServer {
+		// makeB generates b, unless it takes it from an EphemeralPool.
 		if _, err := s.makeB(); err != nil {
 			return nil
 		}
 	} else {
+		s.generateMySecret()
 		if _, err := s.makeA(); err != nil {
 			return nil
 		}
 	}
+	if obs != nil {
+		obs.HandshakeStarted(s.handshakeInfo(), time.Since(start))
+	}
 	return s
 }
 
@@ -196,6 +241,11 @@ func (s *SRP) EphemeralPublic() *big.Int {
 	return s.ephemeralPublicA
 }
 
+// IsServer reports whether s is the server side of the exchange.
+func (s *SRP) IsServer() bool {
+	return s.isServer
+}
+
 /*
 IsPublicValid checks to see whether public A or B is valid within the group.
 
@@ -208,7 +258,11 @@ than using SetOthersPublic(), which also performs this check.
 func (s *SRP) IsPublicValid(AorB *big.Int) bool {
 	// We assume that we have a good s.group
 
-	if s.group.Reduce(AorB).Cmp(bigOne) == 0 {
+	reduced := AorB
+	if !s.group.inRange(AorB) {
+		reduced = s.group.Reduce(AorB)
+	}
+	if reduced.Cmp(bigOne) == 0 {
 		return false
 	}
 
@@ -250,6 +304,7 @@ func (s *SRP) SetOthersPublic(AorB *big.Int) error {
 	if !s.IsPublicValid(AorB) {
 		s.badState = true
 		s.key = nil
+		s.observeRejected(RejectInvalidPublic)
 		return fmt.Errorf("invalid public exponent")
 	}
 
@@ -261,6 +316,96 @@ func (s *SRP) SetOthersPublic(AorB *big.Int) error {
 	return nil
 }
 
+/*
+EphemeralPublicBytes returns EphemeralPublic as big-endian bytes, padded
+to the length of N. This is the encoding SetOthersPublicBytes expects.
+*/
+func (s *SRP) EphemeralPublicBytes() []byte {
+	AorB := s.EphemeralPublic()
+	if AorB == nil {
+		return nil
+	}
+	return s.group.PaddedBytes(AorB)
+}
+
+/*
+SetOthersPublicBytes is SetOthersPublic for the encoding of EphemeralPublicBytes.
+It rejects anything that isn't exactly the length of N or isn't less than N.
+*/
+func (s *SRP) SetOthersPublicBytes(b []byte) error {
+	if len(b) != len(s.group.n.Bytes()) {
+		s.badState = true
+		s.key = nil
+		s.observeRejected(RejectMalformedPublic)
+		return fmt.Errorf("public exponent is %d bytes instead of %d", len(b), len(s.group.n.Bytes()))
+	}
+	return s.setOthersPublicBelowN(new(big.Int).SetBytes(b))
+}
+
+/*
+EphemeralPublicHex returns EphemeralPublic as lower case hex with leading zeros
+removed, the encoding that 1Password servers use.
+*/
+func (s *SRP) EphemeralPublicHex() string {
+	AorB := s.EphemeralPublic()
+	if AorB == nil {
+		return ""
+	}
+	return serverStyleHexFromBigInt(AorB)
+}
+
+/*
+SetOthersPublicHex is SetOthersPublic for hex. It accepts the encoding of
+EphemeralPublicHex, and for compatibility upper case and leading zeros too, but
+nothing longer than the hex of N and nothing that isn't less than N.
+*/
+func (s *SRP) SetOthersPublicHex(h string) error {
+	if len(h) > 2*len(s.group.n.Bytes()) {
+		s.badState = true
+		s.key = nil
+		s.observeRejected(RejectMalformedPublic)
+		return fmt.Errorf("hex public exponent is too long")
+	}
+	AorB, err := ParseNumber(h, Hex)
+	if err != nil {
+		s.badState = true
+		s.key = nil
+		s.observeRejected(RejectMalformedPublic)
+		return err
+	}
+	return s.setOthersPublicBelowN(AorB)
+}
+
+// setOthersPublicBelowN is SetOthersPublic, additionally rejecting values that aren't less than N.
+//
+//nolint:gocritic // A != a. Case matters
+func (s *SRP) setOthersPublicBelowN(AorB *big.Int) error {
+	if AorB.Cmp(s.group.n) >= 0 {
+		s.badState = true
+		s.key = nil
+		s.observeRejected(RejectPublicOutOfRange)
+		return fmt.Errorf("public exponent isn't less than N")
+	}
+	return s.SetOthersPublic(AorB)
+}
+
+/*
+SetX sets the client's long term secret x, replacing the one it was created with.
+
+A is independent of x, so this lets a client send A before it has the salt and
+KDF parameters it needs to derive x. It must be called before Key.
+*/
+func (s *SRP) SetX(x *big.Int) error {
+	if s.isServer {
+		return fmt.Errorf("only a client has x")
+	}
+	if s.key != nil {
+		return fmt.Errorf("x can't be changed once the key has been computed")
+	}
+	s.x.Set(x)
+	return nil
+}
+
 /*
 Key creates and returns the session Key.
 
@@ -293,6 +438,11 @@ func (s *SRP) Key() ([]byte, error) {
 	if s.group.n.Cmp(bigZero) == 0 {
 		return nil, fmt.Errorf("group has 0 modulus")
 	}
+	obs := currentObserver()
+	var start time.Time
+	if obs != nil {
+		start = time.Now()
+	}
 	// Because of tests, we don't want to always recalculate u
 	if !s.isUValid() {
 		if u, err := s.calculateU(); u == nil || err != nil {
@@ -302,22 +452,27 @@ func (s *SRP) Key() ([]byte, error) {
 	// We must refuse to calculate Key when u == 0
 	if !s.isUValid() {
 		s.badState = true
+		s.observeRejected(RejectInvalidU)
 		return nil, fmt.Errorf("invalid u")
 	}
 	if s.group.IsZero(s.ephemeralPrivate) {
 		return nil, fmt.Errorf("cannot make Key with my ephemeral secret")
 	}
 
-	b := &big.Int{} // base
-	e := &big.Int{} // exponent
+	sc := getScratch()
+	defer putScratch(sc)
+	b, e := &sc.t1, &sc.t2 // base and exponent
 
+	// Each side does two exponentiations. Computing the server's as A^b * v^(ub)
+	// in one run is slower in every group; see BenchmarkServerPremaster.
 	if s.isServer {
 		// S = (Av^u) ^ b
 		if s.v == nil || s.ephemeralPublicA == nil {
 			return nil, fmt.Errorf("not enough is known to create Key")
 		}
 		b.Exp(s.v, s.u, s.group.n) // #nosec G105
-		b.Mul(b, s.ephemeralPublicA)
+		sc.prod.Mul(b, s.ephemeralPublicA)
+		sc.mod(b, &sc.prod, s.group.n)
 		e = s.ephemeralPrivate
 	} else { // client
 		// (B - kg^x) ^ (a + ux)
@@ -327,27 +482,35 @@ func (s *SRP) Key() ([]byte, error) {
 		e.Mul(s.u, s.x)
 		e.Add(e, s.ephemeralPrivate)
 
-		b.Exp(s.group.g, s.x, s.group.n) // #nosec G105
-		b.Mul(b, s.k)
-		b.Sub(s.ephemeralPublicB, b)
-		b = s.group.Reduce(b)
+		s.group.expG(b, s.x)
+		sc.prod.Mul(b, s.k) // not into b, which Mul would have to allocate for
+		b.Sub(s.ephemeralPublicB, &sc.prod)
+		sc.mod(b, b, s.group.n)
 	}
 
 	s.premasterKey.Exp(b, e, s.group.n)
 
-	h := Hash.NewWith(s.hashName)
+	h := sc.hash(s.hashName)
 	if h == nil {
 		return nil, fmt.Errorf("failed to set up hash function")
 	}
-	if _, err := h.Write([]byte(fmt.Sprintf("%x", s.premasterKey))); err != nil {
+	// The premaster secret is hashed as lowercase hex without leading zeros, as %x prints it.
+	premasterHex := appendTrimmedHex(sc.buffer(2*s.group.byteLen()), s.premasterKey)
+	if len(premasterHex) == 0 {
+		premasterHex = append(premasterHex, '0')
+	}
+	if _, err := h.Write(premasterHex); err != nil {
 		return nil, fmt.Errorf("failed to write premasterKey to hasher: %w", err)
 	}
 
-	s.key = h.Sum(nil)
+	s.key = h.Sum(s.sums[0][:0])
 
 	if len(s.key) != h.Size() {
 		return nil, fmt.Errorf("key size should be %d, but instead is %d", h.Size(), len(s.key))
 	}
+	if obs != nil {
+		obs.KeyDerived(s.handshakeInfo(), time.Since(start))
+	}
 	return s.key, nil
 }
 
@@ -380,6 +543,11 @@ func (s *SRP) MarshalBinary() (binaryEncoding []byte, err error) {
 		s.isServerProved,
 		s.m,
 		s.cProof,
+		s.isClientProved,
+		s.credChanged,
+		s.isFake,
+		s.stdPadding,
+		s.hashName,
 	}
 	for _, value := range values {
 		if err = enc.Encode(value); err != nil {
@@ -417,5 +585,25 @@ func (s *SRP) UnmarshalBinary(data []byte) (err error) {
 		}
 	}
 
+	// These were added later, so may be missing from older encodings.
+	optional := []interface{}{
+		&s.isClientProved,
+		&s.credChanged,
+		&s.isFake,
+		&s.stdPadding,
+		&s.hashName,
+	}
+	for _, value := range optional {
+		if err = dec.Decode(value); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("decoding failure: %w", err)
+		}
+	}
+	if s.hashName == "" {
+		s.hashName = Hash.Sha256Name
+	}
+
 	return nil
 }
@@ -288,6 +288,103 @@ func TestClientServerMatch(t *testing.T) {
 	}
 }
 
+// TestSetX checks that a client can send A before it knows x.
+func TestSetX(t *testing.T) {
+	grp := KnownGroups[RFC5054Group2048]
+	x := big.NewInt(0x1234567890)
+	v, err := NewClientStd(grp, x).Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClientStd(grp, big.NewInt(0))
+	server := NewServerStd(grp, v)
+	if err := server.SetOthersPublic(client.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetOthersPublic(server.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetX(x); err != nil {
+		t.Fatal(err)
+	}
+	serverKey, _ := server.Key()
+	clientKey, _ := client.Key()
+	if !bytes.Equal(serverKey, clientKey) {
+		t.Error("Server and Client keys don't match")
+	}
+
+	if err := client.SetX(x); err == nil {
+		t.Error("x changed after key was computed")
+	}
+	if err := server.SetX(x); err == nil {
+		t.Error("server accepted x")
+	}
+}
+
+func TestPublicBytesAndHex(t *testing.T) {
+	grp := KnownGroups[RFC5054Group2048]
+	x := big.NewInt(0x1234567890)
+	v, _ := NewClientStd(grp, x).Verifier()
+	nLen := len(grp.N().Bytes())
+
+	client := NewClientStd(grp, x)
+	server := NewServerStd(grp, v)
+	A := client.EphemeralPublicBytes()
+	if len(A) != nLen {
+		t.Errorf("A is %d bytes instead of %d", len(A), nLen)
+	}
+	if err := server.SetOthersPublicBytes(A); err != nil {
+		t.Fatal(err)
+	}
+	B := server.EphemeralPublicHex()
+	if B != serverStyleHexFromBigInt(server.EphemeralPublic()) {
+		t.Error("hex isn't server style")
+	}
+	if err := client.SetOthersPublicHex(B); err != nil {
+		t.Fatal(err)
+	}
+	serverKey, _ := server.Key()
+	clientKey, _ := client.Key()
+	if !bytes.Equal(serverKey, clientKey) {
+		t.Error("Server and Client keys don't match")
+	}
+
+	N := grp.N()
+	NPlusTwo := new(big.Int).Add(N, big.NewInt(2))
+	badBytes := map[string][]byte{
+		"short":  A[1:],
+		"long":   append([]byte{0}, A...),
+		"N":      N.Bytes(),
+		"N + 2":  NPlusTwo.FillBytes(make([]byte, nLen)),
+		"zero":   make([]byte, nLen),
+		"padded": append(make([]byte, nLen-1), 1),
+	}
+	for name, b := range badBytes {
+		if err := NewServerStd(grp, v).SetOthersPublicBytes(b); err == nil {
+			t.Errorf("accepted %s", name)
+		}
+	}
+	badHex := map[string]string{
+		"empty":     "",
+		"not hex":   "xyz",
+		"prefixed":  "0x1234",
+		"N + 2":     NPlusTwo.Text(16),
+		"too long":  "0" + strings.Repeat("f", 2*nLen),
+		"signed":    "-1234",
+		"separated": "12_34",
+	}
+	for name, h := range badHex {
+		if err := NewServerStd(grp, v).SetOthersPublicHex(h); err == nil {
+			t.Errorf("accepted %s hex", name)
+		}
+	}
+	// Upper case and leading zeros are fine
+	if err := NewServerStd(grp, v).SetOthersPublicHex(strings.ToUpper(hex.EncodeToString(A))); err != nil {
+		t.Errorf("rejected compatible hex: %s", err)
+	}
+}
+
 // TestBadA checks that if A mod N = 0 errors are returned and no key created.
 func TestBadA(t *testing.T) {
 	xbytes := make([]byte, 32)
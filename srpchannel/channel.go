@@ -0,0 +1,247 @@
+package srpchannel
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/1Password/srp"
+)
+
+const (
+	clientToServerLabel = "srpchannel client to server v1"
+	serverToClientLabel = "srpchannel server to client v1"
+	rekeyLabel          = "srpchannel rekey v1"
+
+	keySize = 32
+
+	typeData  byte = 0
+	typeClose byte = 1
+)
+
+// DefaultRekeyAfter is the number of messages sent under one key before it is replaced.
+const DefaultRekeyAfter = 1 << 24
+
+/*
+DefaultRekeyAfterBytes is the amount of plaintext sealed under one key before it
+is replaced, 2^36 bytes or 2^32 AES blocks. Messages of up to MaxMessageSize can
+add up to far more than AES-GCM should be used for under one key, about 2^34.5
+blocks, long before DefaultRekeyAfter of them have been sent, so the volume is
+what limits a key in practice.
+*/
+const DefaultRekeyAfterBytes = 1 << 36
+
+// MaxMessageSize is the largest plaintext that can be sealed in one message.
+const MaxMessageSize = 1 << 24
+
+var (
+	// ErrClosed is returned by Seal after SealClose, and by Open after the
+	// close message has been received.
+	ErrClosed = errors.New("srpchannel: closed")
+
+	// ErrTruncated is returned by CheckClosed when the close message hasn't arrived.
+	ErrTruncated = errors.New("srpchannel: stream truncated")
+
+	// ErrBroken is returned after an earlier failure in the same direction.
+	ErrBroken = errors.New("srpchannel: broken by earlier error")
+)
+
+// Config holds settings for a Channel. The zero Config uses the defaults.
+type Config struct {
+	// RekeyAfter is the number of messages sent under one key before it is replaced.
+	// Zero means DefaultRekeyAfter. Both parties must use the same value.
+	RekeyAfter uint64
+
+	// RekeyAfterBytes is the amount of plaintext sealed under one key before it
+	// is replaced, whatever the number of messages. The key is replaced after the
+	// message that reaches it. Zero means DefaultRekeyAfterBytes, and larger
+	// values are reduced to it. Both parties must use the same value.
+	RekeyAfterBytes uint64
+}
+
+// Channel seals outgoing and opens incoming messages for one party.
+// It is safe for concurrent use, but messages must reach the other party
+// in the order they were sealed.
+type Channel struct {
+	send, recv direction
+}
+
+// direction is the state of one direction of a Channel.
+type direction struct {
+	mu         sync.Mutex
+	key        []byte
+	aead       cipher.AEAD
+	epoch      uint32 // number of times the key has been replaced
+	counter    uint64 // messages under the current key
+	bytes      uint64 // plaintext under the current key
+	rekeyAfter uint64
+	rekeyBytes uint64
+	closed     bool
+	broken     bool
+}
+
+// New creates a Channel for the party of s, which must have verified the
+// other party's proof. cfg may be nil.
+func New(s *srp.SRP, cfg *Config) (*Channel, error) {
+	rekeyAfter, rekeyBytes := uint64(DefaultRekeyAfter), uint64(DefaultRekeyAfterBytes)
+	if cfg != nil && cfg.RekeyAfter > 0 {
+		rekeyAfter = cfg.RekeyAfter
+	}
+	if cfg != nil && cfg.RekeyAfterBytes > 0 && cfg.RekeyAfterBytes < rekeyBytes {
+		rekeyBytes = cfg.RekeyAfterBytes
+	}
+
+	c2s, err := s.ExportKeyingMaterial(clientToServerLabel, nil, keySize)
+	if err != nil {
+		return nil, err
+	}
+	s2c, err := s.ExportKeyingMaterial(serverToClientLabel, nil, keySize)
+	if err != nil {
+		return nil, err
+	}
+	sendKey, recvKey := c2s, s2c
+	if s.IsServer() {
+		sendKey, recvKey = s2c, c2s
+	}
+
+	c := &Channel{}
+	if err := c.send.init(sendKey, rekeyAfter, rekeyBytes); err != nil {
+		return nil, err
+	}
+	if err := c.recv.init(recvKey, rekeyAfter, rekeyBytes); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (d *direction) init(key []byte, rekeyAfter, rekeyBytes uint64) error {
+	d.rekeyAfter, d.rekeyBytes = rekeyAfter, rekeyBytes
+	return d.setKey(key)
+}
+
+func (d *direction) setKey(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	d.key, d.aead, d.counter, d.bytes = key, aead, 0, 0
+	return nil
+}
+
+// advance moves on from a message of n bytes of plaintext, replacing the key
+// when its time is up.
+func (d *direction) advance(n int) error {
+	d.counter++
+	d.bytes += uint64(n)
+	if d.counter < d.rekeyAfter && d.bytes < d.rekeyBytes {
+		return nil
+	}
+	if d.epoch == ^uint32(0) {
+		return fmt.Errorf("srpchannel: out of keys")
+	}
+	d.epoch++
+	mac := hmac.New(sha256.New, d.key)
+	mac.Write([]byte(rekeyLabel))
+	return d.setKey(mac.Sum(nil))
+}
+
+// nonce is the epoch and counter.
+func (d *direction) nonce() []byte {
+	nonce := make([]byte, d.aead.NonceSize())
+	binary.BigEndian.PutUint32(nonce[:4], d.epoch)
+	binary.BigEndian.PutUint64(nonce[4:], d.counter)
+	return nonce
+}
+
+func (d *direction) seal(typ byte, plaintext []byte) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.broken {
+		return nil, ErrBroken
+	}
+	if d.closed {
+		return nil, ErrClosed
+	}
+	if len(plaintext) > MaxMessageSize {
+		return nil, fmt.Errorf("srpchannel: message of %d bytes is too long", len(plaintext))
+	}
+	msg := make([]byte, 1, 1+len(plaintext)+d.aead.Overhead())
+	msg[0] = typ
+	msg = d.aead.Seal(msg, d.nonce(), plaintext, msg[:1])
+	if err := d.advance(len(plaintext)); err != nil {
+		d.broken = true
+		return nil, err
+	}
+	if typ == typeClose {
+		d.closed = true
+	}
+	return msg, nil
+}
+
+// Seal encrypts plaintext as the next message to the other party.
+func (c *Channel) Seal(plaintext []byte) ([]byte, error) {
+	return c.send.seal(typeData, plaintext)
+}
+
+// SealClose returns the message that ends the stream. Nothing can be sealed after it.
+func (c *Channel) SealClose() ([]byte, error) {
+	return c.send.seal(typeClose, nil)
+}
+
+// Open decrypts the next message from the other party. It returns io.EOF
+// for the close message and ErrClosed for anything after it.
+func (c *Channel) Open(msg []byte) ([]byte, error) {
+	d := &c.recv
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.broken {
+		return nil, ErrBroken
+	}
+	if d.closed {
+		return nil, ErrClosed
+	}
+	if len(msg) < 1+d.aead.Overhead() || (msg[0] != typeData && msg[0] != typeClose) {
+		d.broken = true
+		return nil, fmt.Errorf("srpchannel: malformed message")
+	}
+	plaintext, err := d.aead.Open(nil, d.nonce(), msg[1:], msg[:1])
+	if err != nil {
+		d.broken = true
+		return nil, fmt.Errorf("srpchannel: message failed to decrypt: %w", err)
+	}
+	if err := d.advance(len(plaintext)); err != nil {
+		d.broken = true
+		return nil, err
+	}
+	if msg[0] == typeClose {
+		d.closed = true
+		return nil, io.EOF
+	}
+	return plaintext, nil
+}
+
+// CheckClosed returns ErrTruncated unless the other party's close message has been
+// opened. Call it when the underlying transport reaches its end.
+func (c *Channel) CheckClosed() error {
+	c.recv.mu.Lock()
+	defer c.recv.mu.Unlock()
+	if !c.recv.closed {
+		return ErrTruncated
+	}
+	return nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,158 @@
+package srpchannel
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/1Password/srp"
+)
+
+// handshake returns a client and server that have completed a handshake.
+func handshake(t *testing.T) (client, server *srp.SRP) {
+	t.Helper()
+	grp := srp.KnownGroups[srp.RFC5054Group2048]
+	x := big.NewInt(0x5eed5eed5eed)
+	v, err := srp.NewClientStd(grp, x).Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = srp.NewClientStd(grp, x)
+	server = srp.NewServerStd(grp, v)
+	if err := server.SetOthersPublic(client.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetOthersPublic(server.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Key(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Key(); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := server.M([]byte("salt"), "alice")
+	if !client.GoodServerProof([]byte("salt"), "alice", m) {
+		t.Fatal("bad server proof")
+	}
+	proof, _ := client.ClientProof()
+	if !server.GoodClientProof(proof) {
+		t.Fatal("bad client proof")
+	}
+	return client, server
+}
+
+func channels(t *testing.T, cfg *Config) (client, server *Channel) {
+	t.Helper()
+	c, s := handshake(t)
+	client, err := New(c, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = New(s, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestChannel(t *testing.T) {
+	client, server := channels(t, &Config{RekeyAfter: 3})
+
+	// Enough messages each way to rekey a few times
+	for i := 0; i < 10; i++ {
+		msg, err := client.Seal([]byte("ping"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := server.Open(msg); err != nil || string(got) != "ping" {
+			t.Fatalf("server got %q, %v", got, err)
+		}
+		msg, err = server.Seal([]byte("pong"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := client.Open(msg); err != nil || string(got) != "pong" {
+			t.Fatalf("client got %q, %v", got, err)
+		}
+	}
+
+	if err := server.CheckClosed(); !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+	closing, err := client.SealClose()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Open(closing); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+	if err := server.CheckClosed(); err != nil {
+		t.Errorf("closed channel reports %v", err)
+	}
+	if _, err := client.Seal([]byte("more")); !errors.Is(err, ErrClosed) {
+		t.Errorf("sealed after close: %v", err)
+	}
+}
+
+func TestRekeyByVolume(t *testing.T) {
+	client, server := channels(t, &Config{RekeyAfterBytes: 100})
+	msg := make([]byte, 30)
+	for i := 0; i < 9; i++ {
+		sealed, err := client.Seal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.Open(sealed); err != nil {
+			t.Fatalf("message %d: %s", i, err)
+		}
+	}
+	// The key is replaced after the 4th and 8th messages, at 120 bytes each time.
+	if client.send.epoch != 2 || server.recv.epoch != 2 {
+		t.Errorf("epochs %d and %d after 270 bytes, expected 2", client.send.epoch, server.recv.epoch)
+	}
+
+	// Larger limits than the default are ignored.
+	client, _ = channels(t, &Config{RekeyAfterBytes: 1 << 62})
+	if client.send.rekeyBytes != DefaultRekeyAfterBytes {
+		t.Errorf("rekeying after %d bytes", client.send.rekeyBytes)
+	}
+}
+
+func TestChannelRejects(t *testing.T) {
+	client, server := channels(t, nil)
+	first, _ := client.Seal([]byte("one"))
+	second, _ := client.Seal([]byte("two"))
+
+	// Reordered
+	if _, err := server.Open(second); err == nil {
+		t.Error("opened message out of order")
+	}
+	// Once broken, stays broken
+	if _, err := server.Open(first); !errors.Is(err, ErrBroken) {
+		t.Errorf("expected ErrBroken, got %v", err)
+	}
+
+	client, server = channels(t, nil)
+	first, _ = client.Seal([]byte("one"))
+	if _, err := server.Open(first); err != nil {
+		t.Fatal(err)
+	}
+	// Replayed
+	if _, err := server.Open(first); err == nil {
+		t.Error("opened replayed message")
+	}
+
+	// A message can't be reflected back to its sender
+	client, _ = channels(t, nil)
+	msg, _ := client.Seal([]byte("hello"))
+	if _, err := client.Open(msg); err == nil {
+		t.Error("opened own message")
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,311 @@
+package srpchannel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+// DefaultHandshakeTimeout limits how long a handshake may take when no timeout is configured.
+const DefaultHandshakeTimeout = 30 * time.Second
+
+// ErrAuthFailed is returned when a handshake fails to authenticate. A wrong
+// password, an unknown identity and a server that can't prove itself all look the same.
+var ErrAuthFailed = errors.New("srpchannel: authentication failed")
+
+// HandshakeError is an error reported by the other party during a handshake.
+type HandshakeError struct {
+	Message string
+	group   string // set when the client picked the wrong group
+}
+
+func (e *HandshakeError) Error() string {
+	return "srpchannel: peer reported: " + e.Message
+}
+
+// Conn is a net.Conn whose reads and writes are encrypted with a Channel,
+// established by an SRP handshake. Conns come from Dial and Listener.Accept.
+type Conn struct {
+	raw     net.Conn
+	timeout time.Duration
+
+	handshakeOnce sync.Once
+	handshakeErr  error
+	handshake     func(c *Conn) error // run by Handshake
+
+	// Set by the handshake
+	ch         *Channel
+	identity   string
+	credential string
+
+	readMu  sync.Mutex
+	frames  frameReader
+	pending []byte // opened but not yet read
+	readErr error
+
+	deadlineMu        sync.Mutex
+	readDeadline      time.Time // as set by the caller
+	writeDeadline     time.Time
+	handshakeDeadline time.Time // zero except during the handshake
+
+	writeMu  sync.Mutex
+	writeErr error
+}
+
+var _ net.Conn = &Conn{} //nolint:exhaustruct
+
+/*
+Handshake runs the handshake if it hasn't already been run, and returns its result.
+Read and Write call it, so there is normally no need to call it directly.
+
+The handshake must finish within the configured timeout, or any earlier deadline
+set on c, after which c's own deadlines apply again. Deadlines should be set on
+c rather than the underlying connection, which is given c's when the handshake
+ends.
+*/
+func (c *Conn) Handshake() error {
+	c.handshakeOnce.Do(func() {
+		if err := c.setHandshakeDeadline(time.Now().Add(c.timeout)); err != nil {
+			c.handshakeErr = err
+			return
+		}
+		c.handshakeErr = c.handshake(c)
+		if err := c.setHandshakeDeadline(time.Time{}); err != nil && c.handshakeErr == nil {
+			c.handshakeErr = err
+		}
+		if c.handshakeErr != nil {
+			_ = c.raw.Close()
+		}
+	})
+	return c.handshakeErr
+}
+
+// Identity returns the identity the client authenticated as.
+// It is empty until the handshake has succeeded.
+func (c *Conn) Identity() string {
+	if c.Handshake() != nil {
+		return ""
+	}
+	return c.identity
+}
+
+// Credential returns the name of the credential the client authenticated with.
+func (c *Conn) Credential() string {
+	if c.Handshake() != nil {
+		return ""
+	}
+	return c.credential
+}
+
+// Read implements net.Conn. It returns io.EOF once the other party has closed
+// the connection, and ErrTruncated if the connection ends without that.
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	for len(c.pending) == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		if c.frames.r == nil {
+			c.frames.r = c.raw
+		}
+		typ, payload, err := c.frames.next()
+		var ne net.Error
+		switch {
+		case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+			c.readErr = ErrTruncated
+			continue
+		case errors.As(err, &ne) && ne.Timeout():
+			// What has been read of a frame is kept, so the caller may set
+			// a new deadline and try again.
+			return 0, err
+		case err != nil:
+			c.readErr = err
+			continue
+		case typ != frameData:
+			c.readErr = fmt.Errorf("srpchannel: unexpected frame type %d", typ)
+			continue
+		}
+		c.pending, err = c.ch.Open(payload)
+		if err != nil {
+			c.readErr = err
+		}
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write implements net.Conn.
+func (c *Conn) Write(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	written := 0
+	for len(b) > 0 {
+		if c.writeErr != nil {
+			return written, c.writeErr
+		}
+		chunk := b
+		if len(chunk) > maxChunkSize {
+			chunk = chunk[:maxChunkSize]
+		}
+		msg, err := c.ch.Seal(chunk)
+		if err == nil {
+			err = writeFrame(c.raw, frameData, msg)
+		}
+		if err != nil {
+			// The channel's counter has moved on, so nothing more can be sent.
+			c.writeErr = err
+			continue
+		}
+		written += len(chunk)
+		b = b[len(chunk):]
+	}
+	return written, nil
+}
+
+// CloseWrite tells the other party that nothing more will be written, but
+// leaves the connection open for reading.
+func (c *Conn) CloseWrite() error {
+	if err := c.Handshake(); err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.closeWrite()
+}
+
+// closeWrite sends the close message. c.writeMu must be held.
+func (c *Conn) closeWrite() error {
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	msg, err := c.ch.SealClose()
+	if err == nil {
+		err = writeFrame(c.raw, frameData, msg)
+	}
+	c.writeErr = errWriteClosed
+	return err
+}
+
+var errWriteClosed = errors.New("srpchannel: write side closed")
+
+// Close tells the other party that nothing more will be written, if that
+// hasn't been done already, and closes the underlying connection.
+func (c *Conn) Close() error {
+	c.writeMu.Lock()
+	if c.ch != nil {
+		_ = c.closeWrite()
+	}
+	c.writeMu.Unlock()
+	return c.raw.Close()
+}
+
+// LocalAddr implements net.Conn.
+func (c *Conn) LocalAddr() net.Addr { return c.raw.LocalAddr() }
+
+// RemoteAddr implements net.Conn.
+func (c *Conn) RemoteAddr() net.Addr { return c.raw.RemoteAddr() }
+
+// SetDeadline implements net.Conn.
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.readDeadline, c.writeDeadline = t, t
+	return c.applyDeadlines()
+}
+
+// SetReadDeadline implements net.Conn.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.readDeadline = t
+	return c.applyDeadlines()
+}
+
+// SetWriteDeadline implements net.Conn.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.writeDeadline = t
+	return c.applyDeadlines()
+}
+
+// setHandshakeDeadline sets the deadline for the handshake, or clears it if t is zero.
+func (c *Conn) setHandshakeDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.handshakeDeadline = t
+	return c.applyDeadlines()
+}
+
+// applyDeadlines gives the underlying connection the earlier of the caller's
+// deadlines and the handshake's. c.deadlineMu must be held.
+func (c *Conn) applyDeadlines() error {
+	if err := c.raw.SetReadDeadline(earliest(c.readDeadline, c.handshakeDeadline)); err != nil {
+		return err
+	}
+	return c.raw.SetWriteDeadline(earliest(c.writeDeadline, c.handshakeDeadline))
+}
+
+// earliest returns the earlier of a and b, where the zero time is no deadline.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// writeMessage writes a handshake message.
+func (c *Conn) writeMessage(m srp.Message) error {
+	payload, err := srp.MarshalMessage(m)
+	if err != nil {
+		return err
+	}
+	return writeFrame(c.raw, frameHandshake, payload)
+}
+
+// readMessage reads a handshake message. An error message is returned as a *HandshakeError.
+func (c *Conn) readMessage() (srp.Message, error) {
+	typ, payload, err := readFrame(c.raw)
+	if err != nil {
+		return nil, err
+	}
+	if typ != frameHandshake {
+		return nil, fmt.Errorf("srpchannel: expected handshake, got frame type %d", typ)
+	}
+	m, err := srp.UnmarshalMessage(payload)
+	if err != nil {
+		return nil, err
+	}
+	if em, ok := m.(*srp.ErrorMessage); ok {
+		return nil, &HandshakeError{Message: em.Message, group: em.Group}
+	}
+	return m, nil
+}
+
+func unexpected(m srp.Message) error {
+	return fmt.Errorf("srpchannel: unexpected %T", m)
+}
+
+// sendError reports msg to the other party and returns err.
+func (c *Conn) sendError(msg, group string, err error) error {
+	_ = c.writeMessage(&srp.ErrorMessage{Message: msg, Group: group})
+	return err
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,263 @@
+package srpchannel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+func enroll(t *testing.T, store srp.VerifierStore, identity, password string, groupID int) {
+	t.Helper()
+	salt := []byte("salt for " + identity)
+	v, err := srp.NewClientStd(srp.KnownGroups[groupID], srp.KDFRFC5054(salt, identity, password)).Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Enroll(context.Background(), &srp.VerifierRecord{
+		Identity: identity,
+		Salt:     salt,
+		GroupID:  groupID,
+		KDF:      srp.KDFParams{Alg: "rfc5054", Iterations: 1},
+		Verifier: v,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// echoServer serves l, echoing back whatever each authenticated client sends
+// after a line with its identity.
+func echoServer(t *testing.T, l net.Listener) {
+	t.Helper()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn *Conn) {
+				defer conn.Close()
+				if conn.Handshake() != nil {
+					return
+				}
+				_, _ = conn.Write([]byte(conn.Identity() + "\n"))
+				_, _ = io.Copy(conn, conn)
+			}(conn.(*Conn))
+		}
+	}()
+}
+
+func TestDialListen(t *testing.T) {
+	store := srp.NewMemoryStore()
+	enroll(t, store, "alice", "password123", srp.RFC5054Group2048)
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(inner, store, &ListenConfig{Channel: &Config{RekeyAfter: 5}})
+	defer l.Close()
+	echoServer(t, l)
+	addr := l.Addr().String()
+
+	// The default group is wrong, so this also checks the retry.
+	conn, err := Dial("tcp", addr, "alice", "password123", &DialConfig{Channel: &Config{RekeyAfter: 5}})
+	if err != nil {
+		t.Fatalf("dial failed: %s", err)
+	}
+
+	message := bytes.Repeat([]byte("0123456789abcdef"), 5000) // several chunks
+	defer conn.Close()
+	go func() {
+		_, _ = conn.Write(message)
+		_ = conn.CloseWrite()
+	}()
+	got, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	if err := conn.ch.CheckClosed(); err != nil {
+		t.Error(err)
+	}
+	expected := append([]byte("alice\n"), message...)
+	if !bytes.Equal(got, expected) {
+		t.Errorf("echo mismatch: got %d bytes, expected %d", len(got), len(expected))
+	}
+
+	if _, err := Dial("tcp", addr, "alice", "wrong", &DialConfig{GroupID: srp.RFC5054Group2048}); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed for wrong password, got %v", err)
+	}
+	if _, err := Dial("tcp", addr, "nobody", "password123", nil); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed for unknown identity, got %v", err)
+	}
+}
+
+func TestClientProvesFirst(t *testing.T) {
+	store := srp.NewMemoryStore()
+	enroll(t, store, "alice", "password123", srp.RFC5054Group2048)
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(inner, store, nil)
+	defer l.Close()
+	echoServer(t, l)
+
+	raw, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	c := &Conn{raw: raw}
+	grp := srp.KnownGroups[srp.RFC5054Group2048]
+	client := srp.NewClientStd(grp, srp.KDFRFC5054([]byte("salt for alice"), "alice", "wrong"))
+	if err := c.writeMessage(&srp.ClientHello{Identity: "alice", Group: grp.Label, A: client.EphemeralPublic()}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := c.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*srp.ServerChallenge); !ok {
+		t.Fatalf("expected a challenge, got %T", m)
+	}
+	if err := c.writeMessage(&srp.ClientProof{Proof: make([]byte, 32)}); err != nil {
+		t.Fatal(err)
+	}
+	m, err = c.readMessage()
+	var he *HandshakeError
+	if !errors.As(err, &he) || he.Message != msgAuthFailed {
+		t.Errorf("expected an authentication failure for a wrong proof, got %T %v", m, err)
+	}
+}
+
+func TestReadTimeoutMidFrame(t *testing.T) {
+	clientCh, serverCh := channels(t, nil)
+	raw, peer := net.Pipe()
+	defer raw.Close()
+	defer peer.Close()
+	c := &Conn{raw: raw, ch: clientCh}
+	c.handshakeOnce.Do(func() {})
+
+	msg, err := serverCh.Seal([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frame bytes.Buffer
+	if err := writeFrame(&frame, frameData, msg); err != nil {
+		t.Fatal(err)
+	}
+	first, rest := frame.Bytes()[:10], frame.Bytes()[10:]
+	sendRest := make(chan struct{})
+	go func() {
+		_, _ = peer.Write(first)
+		<-sendRest
+		_, _ = peer.Write(rest)
+	}()
+
+	if err := c.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	var ne net.Error
+	if _, err := c.Read(make([]byte, 16)); !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	close(sendRest)
+	if err := c.SetReadDeadline(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 16)
+	n, err := c.Read(got)
+	if err != nil || string(got[:n]) != "hello" {
+		t.Errorf("after the timeout read %q, %v", got[:n], err)
+	}
+}
+
+func TestHandshakeKeepsDeadline(t *testing.T) {
+	store := srp.NewMemoryStore()
+	enroll(t, store, "alice", "password123", srp.RFC5054Group2048)
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(inner, store, nil)
+	defer l.Close()
+
+	go func() {
+		conn, err := Dial("tcp", l.Addr().String(), "alice", "password123", &DialConfig{GroupID: srp.RFC5054Group2048})
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second) // say nothing
+		}
+	}()
+	accepted, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer accepted.Close()
+	conn := accepted.(*Conn)
+	if err := conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		var ne net.Error
+		if !errors.As(err, &ne) || !ne.Timeout() {
+			t.Errorf("expected the caller's deadline to time out the read, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the handshake cleared the caller's read deadline")
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(inner, srp.NewMemoryStore(), &ListenConfig{HandshakeTimeout: 50 * time.Millisecond})
+	defer l.Close()
+
+	// A client that connects and says nothing
+	raw, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- conn.(*Conn).Handshake() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("handshake succeeded with a silent client")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handshake didn't time out")
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,230 @@
+package srpchannel
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+/*
+The handshake over a connection goes
+
+	Client -> Server: srp.ClientHello
+	Server -> Client: srp.ServerChallenge
+	Client -> Server: srp.ClientProof
+	Server -> Client: srp.ServerProof
+	Server -> Client: data frame with a sealed empty message
+
+with an srp.ErrorMessage in place of any of the server's messages if something
+goes wrong. The client has to pick the group for A before it knows which group
+its record uses. If it picks the wrong one, the server's error message names the
+right one and the client sends a new hello, once. The client proves itself
+first, and the server sends its proof only once it has accepted the client's, so
+a client that doesn't know the password gets nothing to test guesses against.
+The final empty message is the first sealed with the session's keys; after it,
+data frames carry the Channel's messages in both directions.
+*/
+
+// Messages sent in srp.ErrorMessages
+const (
+	msgAuthFailed = "authentication failed"
+	msgWrongGroup = "wrong group"
+	msgBadMessage = "bad message"
+	msgInternal   = "internal error"
+	msgTooMany    = "too many attempts"
+)
+
+// DialConfig configures Dial. The zero DialConfig uses the defaults.
+type DialConfig struct {
+	// Credential names the credential to use. Empty means the primary credential.
+	Credential string
+
+	// GroupID is the first guess at the group the server uses for the credential.
+	// Zero means srp.RFC5054Group4096.
+	GroupID int
+
+	// KDF derives x from the password. Nil means KDFRFC5054.
+	KDF func(params srp.KDFParams, salt []byte, identity, password string) (*big.Int, error)
+
+	// Timeout limits the time to connect and handshake together.
+	// Zero means DefaultHandshakeTimeout.
+	Timeout time.Duration
+
+	// Channel configures the Channel. Nil means the defaults.
+	Channel *Config
+}
+
+// KDFRFC5054 derives x with srp.KDFRFC5054 for the algorithm "rfc5054".
+// See srp.KDFRFC5054 for why it should not be used.
+func KDFRFC5054(params srp.KDFParams, salt []byte, identity, password string) (*big.Int, error) {
+	if params.Alg != "rfc5054" {
+		return nil, fmt.Errorf("unsupported KDF %q", params.Alg)
+	}
+	return srp.KDFRFC5054(salt, identity, password), nil
+}
+
+// Dial connects to addr on the named network, authenticates as identity with
+// password and returns the encrypted connection. cfg may be nil.
+func Dial(network, addr, identity, password string, cfg *DialConfig) (*Conn, error) {
+	var c DialConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.GroupID == 0 {
+		c.GroupID = srp.RFC5054Group4096
+	}
+	if c.KDF == nil {
+		c.KDF = KDFRFC5054
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultHandshakeTimeout
+	}
+
+	start := time.Now()
+	raw, err := net.DialTimeout(network, addr, c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &Conn{
+		raw:     raw,
+		timeout: c.Timeout - time.Since(start),
+		handshake: func(conn *Conn) error {
+			return conn.clientHandshake(identity, password, &c)
+		},
+	}
+	if err := conn.Handshake(); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *Conn) clientHandshake(identity, password string, cfg *DialConfig) error {
+	grp := srp.KnownGroups[cfg.GroupID]
+	if grp == nil {
+		return fmt.Errorf("srpchannel: unknown group %d", cfg.GroupID)
+	}
+
+	var (
+		client    *srp.SRP
+		challenge *srp.ServerChallenge
+	)
+	for attempt := 0; ; attempt++ {
+		// x is set once we have the salt.
+		client = srp.NewClientStd(grp, big.NewInt(0))
+		if client == nil {
+			return fmt.Errorf("srpchannel: failed to create client")
+		}
+		err := c.writeMessage(&srp.ClientHello{
+			Identity:   identity,
+			Credential: cfg.Credential,
+			Group:      grp.Label,
+			A:          client.EphemeralPublic(),
+		})
+		if err != nil {
+			return err
+		}
+		m, err := c.readMessage()
+		var he *HandshakeError
+		if attempt == 0 && errors.As(err, &he) && he.group != "" {
+			if grp = groupByLabel(he.group); grp == nil {
+				return fmt.Errorf("srpchannel: server wants unknown group %q", he.group)
+			}
+			continue
+		}
+		if err != nil {
+			return clientError(err)
+		}
+		var ok bool
+		if challenge, ok = m.(*srp.ServerChallenge); !ok {
+			return unexpected(m)
+		}
+		break
+	}
+
+	if challenge.Group != grp.Label {
+		return fmt.Errorf("srpchannel: server used group %q instead of %q", challenge.Group, grp.Label)
+	}
+	x, err := cfg.KDF(challenge.KDF, challenge.Salt, identity, password)
+	if err != nil {
+		return err
+	}
+	if err := client.SetX(x); err != nil {
+		return err
+	}
+	if err := client.SetOthersPublic(challenge.B); err != nil {
+		return err
+	}
+	if _, err := client.Key(); err != nil {
+		return err
+	}
+
+	proof, err := client.ClientProofFirst(challenge.Salt, identity)
+	if err != nil {
+		return err
+	}
+	if err := c.writeMessage(&srp.ClientProof{Proof: proof}); err != nil {
+		return err
+	}
+	m, err := c.readMessage()
+	if err != nil {
+		return clientError(err)
+	}
+	serverProof, ok := m.(*srp.ServerProof)
+	if !ok {
+		return unexpected(m)
+	}
+	if !client.GoodServerProof(challenge.Salt, identity, serverProof.Proof) {
+		return ErrAuthFailed
+	}
+
+	ch, err := New(client, cfg.Channel)
+	if err != nil {
+		return err
+	}
+	typ, payload, err := readFrame(c.raw)
+	if err != nil {
+		return err
+	}
+	if typ == frameHandshake {
+		// Nothing but an error message is possible here.
+		return ErrAuthFailed
+	}
+	if typ != frameData {
+		return fmt.Errorf("srpchannel: expected frame type %d, got %d", frameData, typ)
+	}
+	if _, err := ch.Open(payload); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	c.ch, c.identity, c.credential = ch, identity, cfg.Credential
+	c.writeMu.Unlock()
+	return nil
+}
+
+// clientError turns an authentication failure reported by the server into ErrAuthFailed.
+func clientError(err error) error {
+	var he *HandshakeError
+	if errors.As(err, &he) && he.Message == msgAuthFailed {
+		return ErrAuthFailed
+	}
+	return err
+}
+
+func groupByLabel(label string) *srp.Group {
+	for _, grp := range srp.KnownGroups {
+		if grp.Label == label {
+			return grp
+		}
+	}
+	return nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,24 @@
+/*
+Package srpchannel encrypts a stream of messages between the two parties of a
+completed SRP session.
+
+Each direction has its own AES-256-GCM key, exported from the session with
+srp.ExportKeyingMaterial. Nonces are message counters, never sent, so a nonce
+can't be reused and a message that is replayed, reordered or dropped fails to
+decrypt. The sender ends the stream with a close message, so a receiver can tell
+a stream that ended from one that was cut short. Keys are replaced, by both
+sides in step, after a number of messages or an amount of plaintext, whichever
+comes first, well before the GCM limits on the use of one key are reached.
+
+The messages must be delivered reliably and in order, by TCP say. After any
+error a Channel refuses to do anything more in that direction.
+
+Dial and Listener run the handshake itself over a stream connection and return
+a Conn, a net.Conn whose reads and writes go through a Channel.
+*/
+package srpchannel
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
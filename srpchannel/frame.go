@@ -0,0 +1,112 @@
+package srpchannel
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Frames on a connection are a type byte, a big endian uint32 length and the payload.
+// The payload of a handshake frame is a message encoded by srp.MarshalMessage.
+const (
+	frameHandshake byte = 1
+	frameData      byte = 2
+
+	// maxFrameSize limits the payload of any frame, so that a peer can't make
+	// us allocate much before the handshake is done.
+	maxFrameSize = 1 << 16
+
+	// maxChunkSize is the most plaintext sent in one data frame.
+	maxChunkSize = 1 << 14
+)
+
+var errFrameTooLarge = errors.New("srpchannel: frame too large")
+
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return errFrameTooLarge
+	}
+	buf := make([]byte, 5, 5+len(payload))
+	buf[0] = typ
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(payload)))
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > maxFrameSize {
+		return 0, nil, errFrameTooLarge
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+/*
+frameReader reads frames, keeping as much of a frame as it has read when a read
+fails. After a timeout the next call carries on from there, rather than taking
+the middle of a frame for the start of one.
+*/
+type frameReader struct {
+	r   io.Reader
+	buf []byte // the frame read so far
+}
+
+func (fr *frameReader) next() (byte, []byte, error) {
+	for {
+		need, err := fr.need()
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(fr.buf) == need {
+			frame := fr.buf
+			fr.buf = nil
+			return frame[0], frame[5:], nil
+		}
+		if cap(fr.buf) < need {
+			grown := make([]byte, len(fr.buf), need)
+			copy(grown, fr.buf)
+			fr.buf = grown
+		}
+		n, err := fr.r.Read(fr.buf[len(fr.buf):need])
+		fr.buf = fr.buf[:len(fr.buf)+n]
+		if err != nil {
+			// A reader may return the last of a frame along with an error.
+			if need, _ := fr.need(); len(fr.buf) == need {
+				continue
+			}
+			if errors.Is(err, io.EOF) && len(fr.buf) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, nil, err
+		}
+	}
+}
+
+// need returns the length of the frame being read, as far as it is known: just
+// the header until that has been read.
+func (fr *frameReader) need() (int, error) {
+	if len(fr.buf) < 5 {
+		return 5, nil
+	}
+	n := binary.BigEndian.Uint32(fr.buf[1:5])
+	if n > maxFrameSize {
+		return 0, errFrameTooLarge
+	}
+	return 5 + int(n), nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
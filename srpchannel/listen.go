@@ -0,0 +1,271 @@
+package srpchannel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+// ListenConfig configures a Listener. The zero ListenConfig uses the defaults.
+type ListenConfig struct {
+	// Fakes, if set, answers handshakes for unknown identities and credentials
+	// so that they can't be told apart from known ones. If records are enrolled
+	// in more than one group, give it their mix with SetGroupWeights, since a
+	// client in the wrong group is told the record's.
+	Fakes *srp.FakeChallenger
+
+	// Limiter, if set, is asked before each handshake and before the client's
+	// proof is checked, and told whether it was good, with the host of the
+	// connection's remote address as the source.
+	Limiter srp.AttemptLimiter
+
+	// Audit, if set, is sent an event for each login, failed proof, malformed A
+	// and refusal by Limiter, with the same source as Limiter. Failures to audit
+	// are ignored.
+	Audit srp.AuditSink
+
+	// HandshakeTimeout limits the time a handshake may take.
+	// Zero means DefaultHandshakeTimeout.
+	HandshakeTimeout time.Duration
+
+	// Channel configures the Channel. Nil means the defaults.
+	Channel *Config
+}
+
+// Listener accepts connections from an inner net.Listener and authenticates
+// them against the records in a VerifierStore.
+//
+// Like a tls.Listener, it returns connections before their handshakes are done, so
+// that a slow client doesn't hold up others. The handshake runs on the first Read
+// or Write, or on a call to Conn.Handshake.
+type Listener struct {
+	inner net.Listener
+	store srp.VerifierStore
+	cfg   ListenConfig
+}
+
+var _ net.Listener = &Listener{} //nolint:exhaustruct
+
+// NewListener returns a Listener. cfg may be nil.
+func NewListener(inner net.Listener, store srp.VerifierStore, cfg *ListenConfig) *Listener {
+	l := &Listener{inner: inner, store: store}
+	if cfg != nil {
+		l.cfg = *cfg
+	}
+	if l.cfg.HandshakeTimeout == 0 {
+		l.cfg.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	return l
+}
+
+// Accept implements net.Listener. The net.Conn it returns is a *Conn.
+func (l *Listener) Accept() (net.Conn, error) {
+	raw, err := l.inner.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{
+		raw:       raw,
+		timeout:   l.cfg.HandshakeTimeout,
+		handshake: l.serverHandshake,
+	}, nil
+}
+
+// Close implements net.Listener.
+func (l *Listener) Close() error {
+	return l.inner.Close()
+}
+
+// Addr implements net.Listener.
+func (l *Listener) Addr() net.Addr {
+	return l.inner.Addr()
+}
+
+func (l *Listener) serverHandshake(c *Conn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var (
+		rec *srp.VerifierRecord
+		A   *big.Int
+	)
+	for attempt := 0; ; attempt++ {
+		m, err := c.readMessage()
+		if err != nil {
+			return c.sendError(msgBadMessage, "", err)
+		}
+		hello, ok := m.(*srp.ClientHello)
+		if !ok {
+			return c.sendError(msgBadMessage, "", unexpected(m))
+		}
+		A = hello.A
+
+		if attempt == 0 {
+			if err := l.checkLimiter(ctx, c, hello.Identity, false); err != nil {
+				return err
+			}
+		}
+
+		if l.cfg.Fakes != nil {
+			rec, err = l.cfg.Fakes.Lookup(ctx, l.store, hello.Identity, hello.Credential)
+		} else {
+			rec, err = srp.LookupCredential(ctx, l.store, hello.Identity, hello.Credential)
+		}
+		if errors.Is(err, srp.ErrUnknownIdentity) {
+			return c.sendError(msgAuthFailed, "", ErrAuthFailed)
+		}
+		if err != nil {
+			return c.sendError(msgInternal, "", err)
+		}
+		if rec.Group() == nil {
+			return c.sendError(msgInternal, "", fmt.Errorf("srpchannel: record for %q has unknown group %d", rec.Identity, rec.GroupID))
+		}
+		if rec.Group().Label == hello.Group {
+			break
+		}
+		if attempt > 0 {
+			return c.sendError(msgWrongGroup, "", errors.New("srpchannel: client used wrong group twice"))
+		}
+		if err := c.writeMessage(&srp.ErrorMessage{Message: msgWrongGroup, Group: rec.Group().Label}); err != nil {
+			return err
+		}
+	}
+
+	server := srp.NewServerFromRecord(rec)
+	if server == nil {
+		return c.sendError(msgInternal, "", errors.New("srpchannel: failed to create server"))
+	}
+	ev := &srp.AuditEvent{Identity: rec.Identity, Credential: rec.Credential, Group: rec.Group().Label, KDF: &rec.KDF, Fake: rec.IsFake()}
+	if err := server.SetOthersPublic(A); err != nil {
+		ev.Type, ev.Reason = srp.AuditMalformedPublic, err.Error()
+		l.audit(ctx, c, ev)
+		return c.sendError(msgBadMessage, "", err)
+	}
+	if _, err := server.Key(); err != nil {
+		return c.sendError(msgBadMessage, "", err)
+	}
+	proof, err := server.M(rec.Salt, rec.Identity)
+	if err != nil {
+		return c.sendError(msgInternal, "", err)
+	}
+	err = c.writeMessage(&srp.ServerChallenge{
+		Salt:  rec.Salt,
+		Group: rec.Group().Label,
+		KDF:   rec.KDF,
+		B:     server.EphemeralPublic(),
+	})
+	if err != nil {
+		return err
+	}
+
+	m, err := c.readMessage()
+	if err != nil {
+		return err
+	}
+	clientProof, ok := m.(*srp.ClientProof)
+	if !ok {
+		return c.sendError(msgBadMessage, "", unexpected(m))
+	}
+	// Failures on other connections may have come in since this handshake
+	// started. This reserves a slot, which Failed or Succeeded releases.
+	if err := l.checkLimiter(ctx, c, rec.Identity, true); err != nil {
+		return err
+	}
+	if !server.GoodClientProof(clientProof.Proof) {
+		ev.Type = srp.AuditProofFailed
+		l.audit(ctx, c, ev)
+		if l.cfg.Limiter != nil {
+			if err := l.cfg.Limiter.Failed(ctx, rec.Identity, remoteHost(c.raw)); err != nil {
+				return c.sendError(msgInternal, "", err)
+			}
+		}
+		return c.sendError(msgAuthFailed, "", ErrAuthFailed)
+	}
+	if l.cfg.Limiter != nil {
+		if err := l.cfg.Limiter.Succeeded(ctx, rec.Identity, remoteHost(c.raw)); err != nil {
+			return c.sendError(msgInternal, "", err)
+		}
+	}
+	// Only now that the client has proved itself does it get the server's proof.
+	if err := c.writeMessage(&srp.ServerProof{Proof: proof}); err != nil {
+		return err
+	}
+
+	ev.Type = srp.AuditLogin
+	l.audit(ctx, c, ev)
+
+	ch, err := New(server, l.cfg.Channel)
+	if err != nil {
+		return c.sendError(msgInternal, "", err)
+	}
+	ready, err := ch.Seal(nil)
+	if err != nil {
+		return err
+	}
+	if err := writeFrame(c.raw, frameData, ready); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	c.ch, c.identity, c.credential = ch, rec.Identity, rec.Credential
+	c.writeMu.Unlock()
+	return nil
+}
+
+// checkLimiter asks l.cfg.Limiter whether identity may start a handshake from
+// c's remote host or, if proof is set, have its proof checked, reserving a
+// slot, and reports to the client if it may not.
+func (l *Listener) checkLimiter(ctx context.Context, c *Conn, identity string, proof bool) error {
+	if l.cfg.Limiter == nil {
+		return nil
+	}
+	check := l.cfg.Limiter.Check
+	if proof {
+		check = l.cfg.Limiter.Attempt
+	}
+	wait, err := check(ctx, identity, remoteHost(c.raw))
+	if err != nil {
+		return c.sendError(msgInternal, "", err)
+	}
+	if wait > 0 {
+		l.audit(ctx, c, &srp.AuditEvent{
+			Type:     srp.AuditLockout,
+			Identity: identity,
+			Reason:   fmt.Sprintf("retry after %v", wait),
+		})
+		return c.sendError(msgTooMany, "", fmt.Errorf("srpchannel: %q must wait %v", identity, wait))
+	}
+	return nil
+}
+
+// audit sends ev to l.cfg.Audit, if it is set, with the time and the connection's source.
+func (l *Listener) audit(ctx context.Context, c *Conn, ev *srp.AuditEvent) {
+	if l.cfg.Audit == nil {
+		return
+	}
+	ev.Time = time.Now()
+	ev.Source = remoteHost(c.raw)
+	_ = l.cfg.Audit.Audit(ctx, ev)
+}
+
+func remoteHost(c net.Conn) string {
+	addr := c.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,294 @@
+package srphttp
+
+import (
+	"bytes"
+	"context"
+	rand "crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+// ErrAuthFailed is returned by Client when a handshake fails. A wrong password,
+// an unknown identity and a server that can't prove itself all look the same.
+var ErrAuthFailed = errors.New("authentication failed")
+
+// Error is an ErrorResponse received from the server.
+type Error struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // from a Retry-After header in seconds, if any
+	group      string
+	puzzle     []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("srphttp: %d: %s", e.StatusCode, e.Message)
+}
+
+// KDF derives x from a password, as described by params.
+type KDF func(params srp.KDFParams, salt []byte, identity, password string) (*big.Int, error)
+
+// KDFRFC5054 is a KDF for srp.KDFRFC5054, whose algorithm is named "rfc5054".
+// See srp.KDFRFC5054 for why it should not be used.
+func KDFRFC5054(params srp.KDFParams, salt []byte, identity, password string) (*big.Int, error) {
+	if params.Alg != "rfc5054" {
+		return nil, fmt.Errorf("unsupported KDF %q", params.Alg)
+	}
+	return srp.KDFRFC5054(salt, identity, password), nil
+}
+
+// Client talks to a Server.
+type Client struct {
+	baseURL string
+	hc      *http.Client
+
+	// GroupID is the group for enrollments and password changes, and the
+	// first guess at the group for a handshake.
+	GroupID int
+	// KDFParams describes how x is derived for enrollments and password changes.
+	KDFParams srp.KDFParams
+	// KDF derives x for any KDFParams that the server might report.
+	KDF KDF
+	// SaltSize is the size of new salts in bytes.
+	SaltSize int
+}
+
+// Session is an authenticated session with the server.
+type Session struct {
+	ID         string
+	Identity   string
+	Credential string
+	SRP        *srp.SRP
+}
+
+// Key returns the session key.
+func (s *Session) Key() ([]byte, error) {
+	return s.SRP.Key()
+}
+
+// NewClient returns a client for the server at baseURL, using hc to make requests.
+// If hc is nil, http.DefaultClient is used. The defaults for the other settings
+// use the 4096 bit group and KDFRFC5054, which should be replaced.
+func NewClient(baseURL string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		hc:        hc,
+		GroupID:   srp.RFC5054Group4096,
+		KDFParams: srp.KDFParams{Alg: "rfc5054", Iterations: 1},
+		KDF:       KDFRFC5054,
+		SaltSize:  16,
+	}
+}
+
+// Enroll enrolls identity with password.
+func (c *Client) Enroll(ctx context.Context, identity, password string) error {
+	salt, grp, v, err := c.newVerifier(identity, password)
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, "/enroll", &EnrollRequest{
+		Identity: identity,
+		Salt:     salt,
+		Group:    grp.Label,
+		KDF:      c.KDFParams,
+		Verifier: encodeNumber(v),
+	}, nil)
+}
+
+// maxPuzzles is how many puzzles a client solves for one login before giving up.
+const maxPuzzles = 3
+
+// Login authenticates as identity with the primary credential.
+func (c *Client) Login(ctx context.Context, identity, password string) (*Session, error) {
+	return c.LoginCredential(ctx, identity, srp.PrimaryCredential, password)
+}
+
+// LoginCredential authenticates as identity with the named credential.
+func (c *Client) LoginCredential(ctx context.Context, identity, credential, password string) (*Session, error) {
+	grp := srp.KnownGroups[c.GroupID]
+	if grp == nil {
+		return nil, fmt.Errorf("unknown group %d", c.GroupID)
+	}
+
+	// x is set once we have the salt.
+	client := srp.NewClientStd(grp, big.NewInt(0))
+	if client == nil {
+		return nil, fmt.Errorf("failed to create client")
+	}
+	var (
+		start        StartResponse
+		groupRetried bool
+		puzzles      int
+		puzzle       []byte
+		solution     uint64
+	)
+	for {
+		err := c.post(ctx, "/start", &StartRequest{
+			Identity:       identity,
+			Credential:     credential,
+			Group:          grp.Label,
+			A:              client.EphemeralPublicHex(),
+			Puzzle:         puzzle,
+			PuzzleSolution: solution,
+		}, &start)
+		var e *Error
+		if !groupRetried && errors.As(err, &e) && e.StatusCode == http.StatusConflict && e.group != "" {
+			// Try once more with the group the server asks for.
+			groupRetried = true
+			if _, grp, err = groupByLabel(e.group); err != nil {
+				return nil, err
+			}
+			if client = srp.NewClientStd(grp, big.NewInt(0)); client == nil {
+				return nil, fmt.Errorf("failed to create client")
+			}
+			puzzle, solution = nil, 0
+			continue
+		}
+		if puzzles < maxPuzzles && errors.As(err, &e) && e.StatusCode == http.StatusPreconditionRequired && e.puzzle != nil {
+			puzzles++
+			puzzle = e.puzzle
+			if solution, err = srp.SolvePuzzle(ctx, puzzle, srp.HelloPuzzleData(identity, client.EphemeralPublic())); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, authError(err)
+		}
+		break
+	}
+	if start.Group != grp.Label {
+		return nil, fmt.Errorf("server used group %q instead of %q", start.Group, grp.Label)
+	}
+
+	x, err := c.KDF(start.KDF, start.Salt, identity, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.SetX(x); err != nil {
+		return nil, err
+	}
+	if err := client.SetOthersPublicHex(start.B); err != nil {
+		return nil, fmt.Errorf("B: %w", err)
+	}
+	if _, err := client.Key(); err != nil {
+		return nil, err
+	}
+	proof, err := client.ClientProofFirst(start.Salt, identity)
+	if err != nil {
+		return nil, err
+	}
+	var verify VerifyResponse
+	if err := c.post(ctx, "/verify", &VerifyRequest{Session: start.Session, Proof: proof}, &verify); err != nil {
+		return nil, authError(err)
+	}
+	if !client.GoodServerProof(start.Salt, identity, verify.Proof) {
+		return nil, ErrAuthFailed
+	}
+	return &Session{ID: start.Session, Identity: identity, Credential: credential, SRP: client}, nil
+}
+
+// ChangePassword replaces the password of the credential that sess was
+// authenticated with. It uses c's current group and KDF settings.
+// A session can be used for at most one change.
+func (c *Client) ChangePassword(ctx context.Context, sess *Session, password string) error {
+	salt, _, v, err := c.newVerifier(sess.Identity, password)
+	if err != nil {
+		return err
+	}
+	sealed, err := sess.SRP.SealCredentialChange(sess.Identity, sess.Credential, salt, c.GroupID, c.KDFParams, v)
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, "/password", &PasswordChangeRequest{Session: sess.ID, Sealed: sealed}, nil)
+}
+
+// newVerifier creates a new salt and the verifier for it and password.
+func (c *Client) newVerifier(identity, password string) ([]byte, *srp.Group, *big.Int, error) {
+	grp := srp.KnownGroups[c.GroupID]
+	if grp == nil {
+		return nil, nil, nil, fmt.Errorf("unknown group %d", c.GroupID)
+	}
+	salt := make([]byte, c.SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		panic(fmt.Sprintf("Failed to get random bytes: %v", err))
+	}
+	x, err := c.KDF(c.KDFParams, salt, identity, password)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	client := srp.NewClientStd(grp, x)
+	if client == nil {
+		return nil, nil, nil, fmt.Errorf("failed to create client")
+	}
+	v, err := client.Verifier()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return salt, grp, v, nil
+}
+
+// post sends req as JSON to path and decodes the response into resp, if it isn't nil.
+func (c *Client) post(ctx context.Context, path string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hresp, err := c.hc.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer hresp.Body.Close()
+
+	// Responses are small. Anything much bigger is a misbehaving server.
+	r := io.LimitReader(hresp.Body, maxRequestSize)
+	if hresp.StatusCode/100 != 2 {
+		var er ErrorResponse
+		if err := json.NewDecoder(r).Decode(&er); err != nil {
+			er.Error = hresp.Status
+		}
+		e := &Error{StatusCode: hresp.StatusCode, Message: er.Error, group: er.Group, puzzle: er.Puzzle}
+		if secs, err := strconv.Atoi(hresp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return e
+	}
+	if resp == nil {
+		return nil
+	}
+	if err := json.NewDecoder(r).Decode(resp); err != nil {
+		return fmt.Errorf("malformed response: %w", err)
+	}
+	return nil
+}
+
+// authError turns the ways a handshake can be refused into ErrAuthFailed.
+func authError(err error) error {
+	var e *Error
+	if errors.As(err, &e) && (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusNotFound) {
+		return ErrAuthFailed
+	}
+	return err
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
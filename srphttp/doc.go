@@ -0,0 +1,51 @@
+/*
+Package srphttp runs SRP handshakes over HTTP with JSON messages.
+
+Server provides handlers for four endpoints. Numbers (A, B and verifiers) are
+hex strings; salts, proofs and sealed messages are base64, as encoding/json
+encodes []byte.
+
+	POST /enroll    EnrollRequest                       -> 201
+	POST /start     StartRequest                        -> StartResponse
+	POST /verify    VerifyRequest                       -> VerifyResponse
+	POST /password  PasswordChangeRequest               -> 204
+
+Failures are reported with an ErrorResponse and a 4xx or 5xx status.
+
+The client picks the group for A before it knows which group its record uses.
+If the server's record uses another group, /start fails with 409 and the
+record's group in ErrorResponse.Group, and the client starts again with that group.
+
+In this package's protocol the client proves itself first: StartResponse
+carries B, the client sends its proof to /verify, and only once that proof has
+been accepted does VerifyResponse carry the server's proof, which the client
+checks. A client that doesn't know the password learns nothing it can test
+guesses against. Between /start and /verify the server's state is kept in
+memory in Server.Pending, an srp.SessionManager, under an unguessable session
+ID, so both requests must reach the same Server; the manager caps the
+handshakes pending for each identity, and /start fails with 429 past that. Once
+verified, the session is kept in a SessionStore under the same ID. Each wrong
+proof at /verify is still an online guess, so a server facing the internet
+should set Server.Limiter. It counts the wrong proofs, not the handshakes
+started, so a client sent back to /start for the wrong group isn't counted
+twice; /start and /verify fail with 429 and a Retry-After header while it is
+backing off. To keep floods of /start requests from costing it an
+exponentiation each, a server can set Server.Puzzles, with Server.Load as its
+load; while it is loaded, /start fails with 428 and a puzzle in
+ErrorResponse.Puzzle, and the client solves it and starts again.
+
+Client is the matching client, built on http.Client.
+
+After the handshake, requests to the service's own endpoints are signed with a
+key derived from the session key by SigningTransport and checked by
+Server.Authenticate.
+
+Enrollment is not authenticated by this package. Wrap the handler from
+Server.EnrollHandler in whatever authorization the service requires.
+*/
+package srphttp
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
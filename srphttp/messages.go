@@ -0,0 +1,82 @@
+package srphttp
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/1Password/srp"
+)
+
+// EnrollRequest enrolls a new identity.
+type EnrollRequest struct {
+	Identity string        `json:"identity"`
+	Salt     []byte        `json:"salt"`
+	Group    string        `json:"group"`
+	KDF      srp.KDFParams `json:"kdf"`
+	Verifier string        `json:"verifier"`
+}
+
+// StartRequest begins a handshake. Credential is empty for the primary credential.
+// Puzzle and PuzzleSolution are set when the server has asked for a puzzle to be solved.
+type StartRequest struct {
+	Identity       string `json:"identity"`
+	Credential     string `json:"credential,omitempty"`
+	Group          string `json:"group"`
+	A              string `json:"A"`
+	Puzzle         []byte `json:"puzzle,omitempty"`
+	PuzzleSolution uint64 `json:"puzzle_solution,omitempty"`
+}
+
+// StartResponse carries what the client needs to compute the key.
+type StartResponse struct {
+	Session string        `json:"session"`
+	Salt    []byte        `json:"salt"`
+	Group   string        `json:"group"`
+	KDF     srp.KDFParams `json:"kdf"`
+	B       string        `json:"B"`
+}
+
+// VerifyRequest carries the client's proof.
+type VerifyRequest struct {
+	Session string `json:"session"`
+	Proof   []byte `json:"proof"`
+}
+
+// VerifyResponse carries the server's proof, sent once the client's has been accepted.
+type VerifyResponse struct {
+	Proof []byte `json:"proof"`
+}
+
+// PasswordChangeRequest carries a password change sealed with SealPasswordChange
+// in a verified session.
+type PasswordChangeRequest struct {
+	Session string `json:"session"`
+	Sealed  []byte `json:"sealed"`
+}
+
+// ErrorResponse reports a failure. Group is set when a handshake was started
+// with the wrong group, and Puzzle when it must be started with a puzzle solved.
+type ErrorResponse struct {
+	Error  string `json:"error"`
+	Group  string `json:"group,omitempty"`
+	Puzzle []byte `json:"puzzle,omitempty"`
+}
+
+// groupByLabel returns the ID and group with label among srp.KnownGroups.
+func groupByLabel(label string) (int, *srp.Group, error) {
+	for id, grp := range srp.KnownGroups {
+		if grp.Label == label {
+			return id, grp, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("unknown group %q", label)
+}
+
+func encodeNumber(n *big.Int) string {
+	return n.Text(16)
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,508 @@
+package srphttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+// maxRequestSize limits the size of request bodies.
+const maxRequestSize = 64 << 10
+
+// Server serves the SRP endpoints for the records in a VerifierStore.
+type Server struct {
+	store    srp.VerifierStore
+	sessions SessionStore
+
+	// Pending holds handshakes from /start until /verify, in memory, so both
+	// requests of a handshake must reach the same Server. It caps the handshakes
+	// pending for each identity, and its Len is the server's Load.
+	// NewServer sets one with the defaults; replace it before serving to change
+	// them.
+	Pending *srp.SessionManager
+
+	// sessionLocks are held, one per session, while a verified session is
+	// loaded and saved again, so that request counters aren't lost.
+	sessionLocks keyedMutex
+
+	// Fakes, if set, answers handshakes for unknown identities and credentials
+	// so that they can't be told apart from known ones. Without it /start
+	// reports unknown identities with 404. If records are enrolled in more than
+	// one group, give it their mix with SetGroupWeights, since a 409 names the
+	// record's group.
+	Fakes *srp.FakeChallenger
+
+	// Puzzles, if set, makes clients solve a puzzle before /start does any
+	// work for them while the server is loaded. /start answers 428 with a
+	// challenge in ErrorResponse.Puzzle when a solution is needed. Give it the
+	// server's Load as its PuzzleConfig.Load.
+	Puzzles *srp.PuzzleGate
+
+	// Limiter, if set, is asked before each /start and before each proof is
+	// checked at /verify, and told whether the proof was good. Either answers
+	// 429 with a Retry-After header when it says to wait.
+	Limiter srp.AttemptLimiter
+
+	// CounterWindow is how far below the highest counter seen for a session a
+	// signed request's counter may be, for clients that send requests at once.
+	// It is at most MaxCounterWindow. Zero, the default, means each counter
+	// must be higher than the last.
+	CounterWindow int
+
+	// Source returns the source of a request for Limiter and Audit. Nil means
+	// the host of the request's RemoteAddr, which is wrong behind a proxy.
+	Source func(r *http.Request) string
+
+	// Audit, if set, is sent an event for each enrollment, login, failed proof,
+	// password change, malformed A and refusal by Limiter. The request's
+	// User-Agent is the event's client metadata. Failures to audit are ignored.
+	Audit srp.AuditSink
+}
+
+// NewServer returns a Server for the records in store, keeping verified sessions
+// in sessions.
+func NewServer(store srp.VerifierStore, sessions SessionStore) *Server {
+	return &Server{store: store, sessions: sessions, Pending: srp.NewSessionManager(nil), sessionLocks: keyedMutex{}, Fakes: nil, Puzzles: nil, Limiter: nil, CounterWindow: 0, Source: nil, Audit: nil}
+}
+
+// Load returns the number of handshakes pending between /start and /verify,
+// for PuzzleConfig.Load.
+func (s *Server) Load() int {
+	return s.Pending.Len()
+}
+
+// Handler returns a handler serving all the endpoints at their usual paths.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/enroll", s.EnrollHandler())
+	mux.Handle("/start", s.StartHandler())
+	mux.Handle("/verify", s.VerifyHandler())
+	mux.Handle("/password", s.PasswordChangeHandler())
+	return mux
+}
+
+// session is the server's state for a verified session, between requests.
+type session struct {
+	Identity   string
+	Credential string
+	Version    int64  // of the record when the session started
+	Group      string // label
+	KDF        srp.KDFParams
+	Server     []byte // MarshalBinary of the server's SRP
+	Verified   bool   // always set; sessions are only saved once verified
+	Counter    uint64 // the highest of the signed requests
+	Seen       uint64 // bit i is set if Counter-i has been seen
+}
+
+// EnrollHandler returns the handler for EnrollRequest.
+func (s *Server) EnrollHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EnrollRequest
+		serve(w, r, &req, func(ctx context.Context) error {
+			groupID, grp, err := groupByLabel(req.Group)
+			if err != nil {
+				return badRequest(err)
+			}
+			// As in OpenPasswordChange, a verifier of 0 or 1 is refused.
+			v, err := srp.ParseNumberInRange(req.Verifier, srp.Hex, big.NewInt(2), grp.N())
+			if err != nil {
+				return badRequest(fmt.Errorf("verifier: %w", err))
+			}
+			if len(req.Salt) == 0 || len(req.Salt) > srp.MaxSaltSize {
+				return badRequest(fmt.Errorf("salt must be from 1 to %d bytes", srp.MaxSaltSize))
+			}
+			rec := &srp.VerifierRecord{
+				Identity: req.Identity,
+				Salt:     req.Salt,
+				GroupID:  groupID,
+				KDF:      req.KDF,
+				Verifier: v,
+			}
+			if err := rec.Validate(); err != nil {
+				return badRequest(err)
+			}
+			err = s.store.Enroll(ctx, rec)
+			if errors.Is(err, srp.ErrIdentityExists) {
+				return &httpError{status: http.StatusConflict, msg: "identity exists"}
+			}
+			if err != nil {
+				return err
+			}
+			s.audit(ctx, r, &srp.AuditEvent{Type: srp.AuditEnroll, Identity: req.Identity, Credential: srp.PrimaryCredential, Group: grp.Label, KDF: &req.KDF})
+			w.WriteHeader(http.StatusCreated)
+			return nil
+		})
+	})
+}
+
+// StartHandler returns the handler for StartRequest.
+func (s *Server) StartHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req StartRequest
+		serve(w, r, &req, func(ctx context.Context) error {
+			if err := s.checkPuzzle(&req); err != nil {
+				return err
+			}
+			if err := s.checkLimiter(ctx, r, req.Identity, s.source(r), false); err != nil {
+				return err
+			}
+
+			var (
+				rec *srp.VerifierRecord
+				err error
+			)
+			if s.Fakes != nil {
+				rec, err = s.Fakes.Lookup(ctx, s.store, req.Identity, req.Credential)
+			} else {
+				rec, err = srp.LookupCredential(ctx, s.store, req.Identity, req.Credential)
+			}
+			if errors.Is(err, srp.ErrUnknownIdentity) {
+				return &httpError{status: http.StatusNotFound, msg: "unknown identity"}
+			}
+			if err != nil {
+				return err
+			}
+			grp := rec.Group()
+			if grp == nil {
+				return fmt.Errorf("record for %q has unknown group %d", rec.Identity, rec.GroupID)
+			}
+			if grp.Label != req.Group {
+				return &httpError{status: http.StatusConflict, msg: "wrong group", group: grp.Label}
+			}
+
+			server := srp.NewServerFromRecord(rec)
+			if server == nil {
+				return fmt.Errorf("failed to create server for %q", rec.Identity)
+			}
+			if err := server.SetOthersPublicHex(req.A); err != nil {
+				s.audit(ctx, r, &srp.AuditEvent{
+					Type:       srp.AuditMalformedPublic,
+					Identity:   rec.Identity,
+					Credential: rec.Credential,
+					Group:      grp.Label,
+					KDF:        &rec.KDF,
+					Reason:     err.Error(),
+					Fake:       rec.IsFake(),
+				})
+				return badRequest(fmt.Errorf("A: %w", err))
+			}
+			if _, err := server.Key(); err != nil {
+				return badRequest(err)
+			}
+
+			p, err := s.Pending.Start(rec, server)
+			if errors.Is(err, srp.ErrTooManyPending) {
+				return &httpError{status: http.StatusTooManyRequests, msg: "too many pending handshakes"}
+			}
+			if err != nil {
+				return err
+			}
+			return writeJSON(w, http.StatusOK, &StartResponse{
+				Session: p.ID,
+				Salt:    rec.Salt,
+				Group:   grp.Label,
+				KDF:     rec.KDF,
+				B:       server.EphemeralPublicHex(),
+			})
+		})
+	})
+}
+
+// VerifyHandler returns the handler for VerifyRequest. The server's proof is
+// sent only once the client's has been accepted. A handshake gets one chance to
+// verify: it is taken from Pending before the proof is checked, and the session
+// is saved in the SessionStore only if the proof is good.
+func (s *Server) VerifyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req VerifyRequest
+		serve(w, r, &req, func(ctx context.Context) error {
+			p, err := s.Pending.Take(req.Session)
+			if errors.Is(err, srp.ErrNoSession) {
+				if _, loadErr := s.sessions.Load(ctx, req.Session); loadErr == nil {
+					return errAlreadyVerified
+				}
+				// Unknown, expired, or taken by a concurrent /verify
+				return errAuthFailed
+			}
+			if err != nil {
+				return err
+			}
+			rec := p.Record
+			ev := &srp.AuditEvent{Identity: rec.Identity, Credential: rec.Credential, Group: rec.Group().Label, KDF: &rec.KDF, Fake: rec.IsFake()}
+			// Failures from other sessions may have come in since this one started.
+			// This reserves a slot with the Limiter, which is released below
+			// however the proof turns out.
+			if err := s.checkLimiter(ctx, r, rec.Identity, s.source(r), true); err != nil {
+				return err
+			}
+			proof, err := p.CheckProof(req.Proof)
+			if err != nil {
+				if errors.Is(err, srp.ErrBadClientProof) {
+					ev.Type = srp.AuditProofFailed
+					s.audit(ctx, r, ev)
+					err = errAuthFailed
+				}
+				if s.Limiter != nil {
+					if failErr := s.Limiter.Failed(ctx, rec.Identity, s.source(r)); failErr != nil {
+						return failErr
+					}
+				}
+				return err
+			}
+			if s.Limiter != nil {
+				if err := s.Limiter.Succeeded(ctx, rec.Identity, s.source(r)); err != nil {
+					return err
+				}
+			}
+			if err := s.save(ctx, p.ID, &session{
+				Identity:   rec.Identity,
+				Credential: rec.Credential,
+				Version:    rec.Version,
+				Group:      rec.Group().Label,
+				KDF:        rec.KDF,
+				Verified:   true,
+			}, p.Server); err != nil {
+				return err
+			}
+			ev.Type = srp.AuditLogin
+			s.audit(ctx, r, ev)
+			return writeJSON(w, http.StatusOK, &VerifyResponse{Proof: proof})
+		})
+	})
+}
+
+// PasswordChangeHandler returns the handler for PasswordChangeRequest.
+// It changes the credential that the session was verified with.
+func (s *Server) PasswordChangeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req PasswordChangeRequest
+		serve(w, r, &req, func(ctx context.Context) error {
+			unlock := s.sessionLocks.lock(req.Session)
+			defer unlock()
+			sess, server, err := s.load(ctx, req.Session)
+			if err != nil {
+				return err
+			}
+			if !sess.Verified {
+				return errAuthFailed
+			}
+			rec, err := srp.LookupCredential(ctx, s.store, sess.Identity, sess.Credential)
+			if errors.Is(err, srp.ErrUnknownIdentity) {
+				// Revoked since the session started
+				return errAuthFailed
+			}
+			if err != nil {
+				return err
+			}
+			// Refuse the change if the record has changed since the session started.
+			rec.Version = sess.Version
+			err = srp.ChangePassword(ctx, s.store, server, rec, req.Sealed)
+			// Whether or not it worked, the session may not be used for another change.
+			if saveErr := s.save(ctx, req.Session, sess, server); saveErr != nil && err == nil {
+				err = saveErr
+			}
+			if errors.Is(err, srp.ErrVersionConflict) {
+				return &httpError{status: http.StatusConflict, msg: "record changed concurrently"}
+			}
+			if errors.Is(err, srp.ErrBadCredentialChange) || errors.Is(err, srp.ErrInvalidName) {
+				return badRequest(err)
+			}
+			if err != nil {
+				return err
+			}
+			s.audit(ctx, r, &srp.AuditEvent{
+				Type:       srp.AuditVerifierChange,
+				Identity:   sess.Identity,
+				Credential: sess.Credential,
+				Group:      rec.Group().Label, // rec is now the new record
+				KDF:        &rec.KDF,
+				Reason:     "password change",
+			})
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		})
+	})
+}
+
+// checkPuzzle checks the puzzle solution in req, if s.Puzzles wants one.
+func (s *Server) checkPuzzle(req *StartRequest) error {
+	if s.Puzzles == nil {
+		return nil
+	}
+	A, err := srp.ParseNumber(req.A, srp.Hex)
+	if err != nil {
+		return badRequest(fmt.Errorf("A: %w", err))
+	}
+	err = s.Puzzles.Check(req.Puzzle, srp.HelloPuzzleData(req.Identity, A), req.PuzzleSolution)
+	if errors.Is(err, srp.ErrPuzzleRequired) || errors.Is(err, srp.ErrBadPuzzle) {
+		challenge := s.Puzzles.Challenge()
+		if challenge == nil {
+			// The load dropped after a bad solution.
+			return badRequest(err)
+		}
+		return &httpError{status: http.StatusPreconditionRequired, msg: err.Error(), puzzle: challenge}
+	}
+	return err
+}
+
+// checkLimiter asks s.Limiter whether identity may start a handshake from
+// source or, if proof is set, have a client's proof checked, in which case a
+// nil return means a slot has been reserved.
+func (s *Server) checkLimiter(ctx context.Context, r *http.Request, identity, source string, proof bool) error {
+	if s.Limiter == nil {
+		return nil
+	}
+	check := s.Limiter.Check
+	if proof {
+		check = s.Limiter.Attempt
+	}
+	wait, err := check(ctx, identity, source)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		s.audit(ctx, r, &srp.AuditEvent{
+			Type:     srp.AuditLockout,
+			Identity: identity,
+			Reason:   fmt.Sprintf("retry after %v", wait),
+		})
+		return &httpError{status: http.StatusTooManyRequests, msg: "too many attempts", retryAfter: wait}
+	}
+	return nil
+}
+
+// audit sends ev to s.Audit, if it is set, with the time and the request's source and client.
+func (s *Server) audit(ctx context.Context, r *http.Request, ev *srp.AuditEvent) {
+	if s.Audit == nil {
+		return
+	}
+	ev.Time = time.Now()
+	ev.Source = s.source(r)
+	ev.Client = r.UserAgent()
+	_ = s.Audit.Audit(ctx, ev)
+}
+
+func (s *Server) source(r *http.Request) string {
+	if s.Source != nil {
+		return s.Source(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (s *Server) save(ctx context.Context, id string, sess *session, server *srp.SRP) error {
+	state, err := server.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	sess.Server = state
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(sess); err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	return s.sessions.Save(ctx, id, buf.Bytes())
+}
+
+func (s *Server) load(ctx context.Context, id string) (*session, *srp.SRP, error) {
+	state, err := s.sessions.Load(ctx, id)
+	if errors.Is(err, ErrNoSession) {
+		return nil, nil, errAuthFailed
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeSession(state)
+}
+
+// decodeSession decodes state saved by save.
+func decodeSession(state []byte) (*session, *srp.SRP, error) {
+	var sess session
+	if err := gob.NewDecoder(bytes.NewReader(state)).Decode(&sess); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	server := new(srp.SRP)
+	if err := server.UnmarshalBinary(sess.Server); err != nil {
+		return nil, nil, err
+	}
+	return &sess, server, nil
+}
+
+// httpError is an error with the status and message to report to the client.
+// Other errors are reported as internal server errors without detail.
+type httpError struct {
+	status     int
+	msg        string
+	group      string
+	puzzle     []byte
+	retryAfter time.Duration
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+var (
+	errAuthFailed      = &httpError{status: http.StatusUnauthorized, msg: "authentication failed"}
+	errAlreadyVerified = &httpError{status: http.StatusConflict, msg: "session already verified"}
+)
+
+func badRequest(err error) error {
+	return &httpError{status: http.StatusBadRequest, msg: err.Error()}
+}
+
+// serve decodes a JSON request into req and calls f, reporting any error
+// as an ErrorResponse.
+func serve(w http.ResponseWriter, r *http.Request, req interface{}, f func(ctx context.Context) error) {
+	err := decodeRequest(w, r, req)
+	if err == nil {
+		err = f(r.Context())
+	}
+	if err == nil {
+		return
+	}
+	var he *httpError
+	if !errors.As(err, &he) {
+		he = &httpError{status: http.StatusInternalServerError, msg: "internal error"}
+	}
+	if he.retryAfter > 0 {
+		// Whole seconds, rounded up
+		w.Header().Set("Retry-After", strconv.FormatInt(int64((he.retryAfter+time.Second-1)/time.Second), 10))
+	}
+	_ = writeJSON(w, he.status, &ErrorResponse{Error: he.msg, Group: he.group, Puzzle: he.puzzle})
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) error {
+	if r.Method != http.MethodPost {
+		return &httpError{status: http.StatusMethodNotAllowed, msg: "method not allowed"}
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		return badRequest(fmt.Errorf("malformed request: %w", err))
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,561 @@
+package srphttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	server := NewServer(srp.NewMemoryStore(), NewMemorySessionStore(time.Minute))
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return server, ts
+}
+
+func TestEndToEnd(t *testing.T) {
+	ctx := context.Background()
+	_, ts := newTestServer(t)
+
+	enroller := NewClient(ts.URL, ts.Client())
+	enroller.GroupID = srp.RFC5054Group2048
+	if err := enroller.Enroll(ctx, "alice", "password123"); err != nil {
+		t.Fatalf("enroll failed: %s", err)
+	}
+	var e *Error
+	if err := enroller.Enroll(ctx, "alice", "password123"); !errors.As(err, &e) || e.StatusCode != http.StatusConflict {
+		t.Errorf("expected conflict on second enroll, got %v", err)
+	}
+
+	// This client starts with the wrong group and has to try again.
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group3072
+	sess, err := client.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	if key, err := sess.Key(); err != nil || len(key) == 0 {
+		t.Errorf("no session key: %v", err)
+	}
+
+	if _, err := client.Login(ctx, "alice", "wrong"); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed for wrong password, got %v", err)
+	}
+
+	if err := client.ChangePassword(ctx, sess, "new password"); err != nil {
+		t.Fatalf("password change failed: %s", err)
+	}
+	if err := client.ChangePassword(ctx, sess, "another password"); err == nil {
+		t.Error("session used for a second password change")
+	}
+	if _, err := client.Login(ctx, "alice", "password123"); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("old password still works: %v", err)
+	}
+	if _, err := client.Login(ctx, "alice", "new password"); err != nil {
+		t.Errorf("new password doesn't work: %s", err)
+	}
+}
+
+func TestEnrollBadVerifier(t *testing.T) {
+	_, ts := newTestServer(t)
+	client := NewClient(ts.URL, ts.Client())
+	grp := srp.KnownGroups[srp.RFC5054Group2048]
+	for _, v := range []string{"0", "1", grp.N().Text(16)} {
+		err := client.post(context.Background(), "/enroll", &EnrollRequest{
+			Identity: "alice",
+			Salt:     []byte("salt"),
+			Group:    grp.Label,
+			KDF:      client.KDFParams,
+			Verifier: v,
+		}, nil)
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for verifier %s, got %v", v, err)
+		}
+	}
+	err := client.post(context.Background(), "/enroll", &EnrollRequest{
+		Identity: "alice",
+		Salt:     []byte("salt"),
+		Group:    grp.Label,
+		KDF:      client.KDFParams,
+		Verifier: "2",
+	}, nil)
+	if err != nil {
+		t.Errorf("smallest verifier refused: %s", err)
+	}
+}
+
+func TestEnrollBadRecord(t *testing.T) {
+	_, ts := newTestServer(t)
+	client := NewClient(ts.URL, ts.Client())
+	grp := srp.KnownGroups[srp.RFC5054Group2048]
+	for _, tc := range []struct {
+		name     string
+		identity string
+		salt     []byte
+	}{
+		{"no identity", "", []byte("salt")},
+		{"zero byte in identity", "alice\x00recovery-1", []byte("salt")},
+		{"no salt", "alice", nil},
+		{"long salt", "alice", make([]byte, srp.MaxSaltSize+1)},
+	} {
+		err := client.post(context.Background(), "/enroll", &EnrollRequest{
+			Identity: tc.identity,
+			Salt:     tc.salt,
+			Group:    grp.Label,
+			KDF:      client.KDFParams,
+			Verifier: "2",
+		}, nil)
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %v", tc.name, err)
+		}
+	}
+}
+
+// failingUpdates is a VerifierStore whose updates fail.
+type failingUpdates struct {
+	srp.VerifierStore
+}
+
+func (failingUpdates) Update(context.Context, *srp.VerifierRecord) error {
+	return errors.New("database on fire")
+}
+
+func TestPasswordChangeErrors(t *testing.T) {
+	ctx := context.Background()
+	store := srp.NewMemoryStore()
+	ts := httptest.NewServer(NewServer(failingUpdates{store}, NewMemorySessionStore(time.Minute)).Handler())
+	defer ts.Close()
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+	if err := client.Enroll(ctx, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := client.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.post(ctx, "/password", &PasswordChangeRequest{Session: sess.ID, Sealed: []byte("garbage")}, nil)
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad sealed change, got %v", err)
+	}
+
+	sess, err = client.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.ChangePassword(ctx, sess, "new password")
+	if !errors.As(err, &e) || e.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected 500 for a store failure, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "fire") {
+		t.Errorf("internal error text sent to the client: %v", err)
+	}
+}
+
+func TestUnknownIdentity(t *testing.T) {
+	ctx := context.Background()
+	server, ts := newTestServer(t)
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+
+	if _, err := client.Login(ctx, "nobody", "password"); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed, got %v", err)
+	}
+
+	fakes, err := srp.NewFakeChallenger(bytes.Repeat([]byte{1}, srp.MinFakeSecretSize),
+		srp.RFC5054Group2048, client.KDFParams, client.SaltSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Fakes = fakes
+	if _, err := client.Login(ctx, "nobody", "password"); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed with fakes, got %v", err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	server, ts := newTestServer(t)
+	server.Limiter = srp.NewMemoryLimiter(&srp.LimiterConfig{
+		Identity: srp.LimitPolicy{FreeAttempts: 1, BaseDelay: 90 * time.Second},
+		Source:   srp.LimitPolicy{FreeAttempts: 100},
+	})
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+	if err := client.Enroll(ctx, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Starting again with the right group doesn't count as another failure.
+	retrier := NewClient(ts.URL, ts.Client())
+	retrier.GroupID = srp.RFC5054Group3072
+	if _, err := retrier.Login(ctx, "alice", "wrong"); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("expected ErrAuthFailed, got %v", err)
+	}
+	if _, err := retrier.Login(ctx, "alice", "password123"); err != nil {
+		t.Fatalf("login after one failure failed: %s", err)
+	}
+
+	// Successes don't add up.
+	for i := 0; i < 3; i++ {
+		if _, err := client.Login(ctx, "alice", "password123"); err != nil {
+			t.Fatalf("login %d failed: %s", i, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Login(ctx, "alice", "wrong"); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("expected ErrAuthFailed, got %v", err)
+		}
+	}
+	_, err := client.Login(ctx, "alice", "password123")
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %v", err)
+	}
+	if e.RetryAfter <= 0 || e.RetryAfter > 90*time.Second {
+		t.Errorf("Retry-After of %v", e.RetryAfter)
+	}
+}
+
+func TestLimiterConcurrentProofs(t *testing.T) {
+	ctx := context.Background()
+	server, ts := newTestServer(t)
+	server.Limiter = srp.NewMemoryLimiter(&srp.LimiterConfig{
+		Identity: srp.LimitPolicy{FreeAttempts: 1, BaseDelay: time.Minute},
+	})
+	const n = 8
+	server.Pending = srp.NewSessionManager(&srp.SessionConfig{MaxPendingPerIdentity: n})
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+	if err := client.Enroll(ctx, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	grp := srp.KnownGroups[srp.RFC5054Group2048]
+	sessions := make([]string, n)
+	for i := range sessions {
+		var start StartResponse
+		a := srp.NewClientStd(grp, big.NewInt(0))
+		if err := client.post(ctx, "/start", &StartRequest{Identity: "alice", Group: grp.Label, A: a.EphemeralPublicHex()}, &start); err != nil {
+			t.Fatalf("start %d failed: %s", i, err)
+		}
+		sessions[i] = start.Session
+	}
+
+	// Wrong proofs sent at once are checked no faster than one after another.
+	results := make(chan error, n)
+	for _, id := range sessions {
+		go func(id string) {
+			results <- client.post(ctx, "/verify", &VerifyRequest{Session: id, Proof: make([]byte, 32)}, nil)
+		}(id)
+	}
+	checked := 0
+	for i := 0; i < n; i++ {
+		var e *Error
+		err := <-results
+		switch {
+		case errors.As(err, &e) && e.StatusCode == http.StatusUnauthorized:
+			checked++
+		case errors.As(err, &e) && e.StatusCode == http.StatusTooManyRequests:
+		default:
+			t.Errorf("expected 401 or 429, got %v", err)
+		}
+	}
+	if checked != 2 {
+		t.Errorf("%d of %d concurrent wrong proofs were checked, want 2", checked, n)
+	}
+}
+
+func TestPuzzles(t *testing.T) {
+	ctx := context.Background()
+	server, ts := newTestServer(t)
+	server.Pending = srp.NewSessionManager(&srp.SessionConfig{MaxPendingPerIdentity: 10})
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+	if err := client.Enroll(ctx, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	puzzles, err := srp.NewPuzzleGate(&srp.PuzzleConfig{
+		Load:           server.Load,
+		Threshold:      2,
+		Step:           2,
+		BaseDifficulty: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Puzzles = puzzles
+
+	// start leaves a handshake pending, solving a puzzle if it must, and
+	// returns the difficulty of the puzzle.
+	grp := srp.KnownGroups[srp.RFC5054Group2048]
+	start := func() int {
+		t.Helper()
+		a := srp.NewClientStd(grp, big.NewInt(0))
+		req := &StartRequest{Identity: "alice", Group: grp.Label, A: a.EphemeralPublicHex()}
+		err := client.post(ctx, "/start", req, nil)
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != http.StatusPreconditionRequired || e.puzzle == nil {
+			if err != nil {
+				t.Fatalf("start failed: %s", err)
+			}
+			return 0
+		}
+		d, err := srp.PuzzleDifficulty(e.puzzle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Puzzle = e.puzzle
+		if req.PuzzleSolution, err = srp.SolvePuzzle(ctx, e.puzzle, srp.HelloPuzzleData("alice", a.EphemeralPublic())); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.post(ctx, "/start", req, nil); err != nil {
+			t.Fatalf("start with a solution failed: %s", err)
+		}
+		return d
+	}
+	// The difficulty rises with the handshakes left pending.
+	for i, want := range []int{0, 0, 4, 4, 5, 5, 6} {
+		if d := start(); d != want {
+			t.Errorf("start with %d pending asked for difficulty %d, want %d", i, d, want)
+		}
+	}
+	if server.Load() != 7 {
+		t.Errorf("load of %d", server.Load())
+	}
+
+	err = client.post(ctx, "/start", &StartRequest{Identity: "alice", Group: "2048", A: "1234"}, nil)
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusPreconditionRequired || e.puzzle == nil {
+		t.Fatalf("expected 428 with a puzzle, got %v", err)
+	}
+
+	// This client starts with the wrong group too, so has to solve a puzzle for each.
+	client.GroupID = srp.RFC5054Group3072
+	if _, err := client.Login(ctx, "alice", "password123"); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	if server.Load() != 7 {
+		t.Errorf("load of %d after a login", server.Load())
+	}
+}
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	server, ts := newTestServer(t)
+	var buf bytes.Buffer
+	server.Audit = srp.NewJSONLinesSink(&buf)
+
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+	if err := client.Enroll(ctx, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	sess, err := client.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ChangePassword(ctx, sess, "new password"); err != nil {
+		t.Fatal(err)
+	}
+	group := srp.KnownGroups[srp.RFC5054Group2048].Label
+	_ = client.post(ctx, "/start", &StartRequest{Identity: "alice", Group: group, A: "0"}, nil)
+
+	var types []srp.AuditEventType
+	dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for dec.More() {
+		var ev srp.AuditEvent
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Identity != "alice" || ev.Group != group || ev.Source == "" || ev.Client == "" || ev.Time.IsZero() {
+			t.Errorf("incomplete event %+v", ev)
+		}
+		if ev.KDF == nil {
+			t.Errorf("%s event has no KDF profile", ev.Type)
+		}
+		types = append(types, ev.Type)
+	}
+	want := []srp.AuditEventType{srp.AuditEnroll, srp.AuditLogin, srp.AuditVerifierChange, srp.AuditMalformedPublic}
+	if len(types) != len(want) {
+		t.Fatalf("events %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("events %v, want %v", types, want)
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("password123")) || bytes.Contains(buf.Bytes(), []byte(sess.SRP.EphemeralPublicHex())) {
+		t.Error("audit trail contains secrets")
+	}
+	if bytes.Contains(buf.Bytes(), []byte(`"fake"`)) {
+		t.Error("events for a real record are marked fake")
+	}
+
+	// A handshake for an unknown identity is marked as answered with a fake record.
+	fakes, err := srp.NewFakeChallenger(bytes.Repeat([]byte{1}, srp.MinFakeSecretSize),
+		srp.RFC5054Group2048, client.KDFParams, client.SaltSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Fakes = fakes
+	buf.Reset()
+	if _, err := client.Login(ctx, "mallory", "password"); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("expected ErrAuthFailed, got %v", err)
+	}
+	var ev srp.AuditEvent
+	if err := json.Unmarshal(buf.Bytes(), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != srp.AuditProofFailed || !ev.Fake {
+		t.Errorf("expected a proof_failed event marked fake, got %+v", ev)
+	}
+}
+
+func TestVerifyUnknownSession(t *testing.T) {
+	_, ts := newTestServer(t)
+	client := NewClient(ts.URL, ts.Client())
+	err := client.post(context.Background(), "/verify", &VerifyRequest{Session: "nope", Proof: []byte{1}}, nil)
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %v", err)
+	}
+}
+
+func TestClientProvesFirst(t *testing.T) {
+	ctx := context.Background()
+	_, ts := newTestServer(t)
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+	if err := client.Enroll(ctx, "alice", "password123"); err != nil {
+		t.Fatalf("enroll failed: %s", err)
+	}
+
+	a := srp.NewClientStd(srp.KnownGroups[srp.RFC5054Group2048], big.NewInt(0))
+	var start map[string]json.RawMessage
+	if err := client.post(ctx, "/start", &StartRequest{
+		Identity: "alice",
+		Group:    srp.KnownGroups[srp.RFC5054Group2048].Label,
+		A:        a.EphemeralPublicHex(),
+	}, &start); err != nil {
+		t.Fatalf("start failed: %s", err)
+	}
+	if _, ok := start["proof"]; ok {
+		t.Error("/start sent a proof before the client proved itself")
+	}
+	var id string
+	if err := json.Unmarshal(start["session"], &id); err != nil {
+		t.Fatal(err)
+	}
+	var verify VerifyResponse
+	err := client.post(ctx, "/verify", &VerifyRequest{Session: id, Proof: make([]byte, 32)}, &verify)
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong proof, got %v", err)
+	}
+	if verify.Proof != nil {
+		t.Error("/verify sent the server's proof for a wrong client proof")
+	}
+}
+
+func TestVerifyOnce(t *testing.T) {
+	ctx := context.Background()
+	_, ts := newTestServer(t)
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+	if err := client.Enroll(ctx, "alice", "password123"); err != nil {
+		t.Fatalf("enroll failed: %s", err)
+	}
+
+	grp := srp.KnownGroups[srp.RFC5054Group2048]
+	a := srp.NewClientStd(grp, big.NewInt(0))
+	var start StartResponse
+	if err := client.post(ctx, "/start", &StartRequest{Identity: "alice", Group: grp.Label, A: a.EphemeralPublicHex()}, &start); err != nil {
+		t.Fatalf("start failed: %s", err)
+	}
+	if err := a.SetX(srp.KDFRFC5054(start.Salt, "alice", "password123")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetOthersPublicHex(start.B); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Key(); err != nil {
+		t.Fatal(err)
+	}
+	proof, err := a.ClientProofFirst(start.Salt, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Of several /verify requests with the same good proof at once, one succeeds.
+	const n = 8
+	results := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			results <- client.post(ctx, "/verify", &VerifyRequest{Session: start.Session, Proof: proof}, nil)
+		}()
+	}
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		if err := <-results; err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d of %d concurrent verifies succeeded", succeeded, n)
+	}
+}
+
+func TestPendingCap(t *testing.T) {
+	ctx := context.Background()
+	server, ts := newTestServer(t)
+	server.Pending = srp.NewSessionManager(&srp.SessionConfig{MaxPendingPerIdentity: 2})
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+	for _, identity := range []string{"alice", "bob"} {
+		if err := client.Enroll(ctx, identity, "password123"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	grp := srp.KnownGroups[srp.RFC5054Group2048]
+	start := func() error {
+		a := srp.NewClientStd(grp, big.NewInt(0))
+		return client.post(ctx, "/start", &StartRequest{Identity: "alice", Group: grp.Label, A: a.EphemeralPublicHex()}, nil)
+	}
+	for i := 0; i < 2; i++ {
+		if err := start(); err != nil {
+			t.Fatalf("start %d failed: %s", i, err)
+		}
+	}
+	var e *Error
+	if err := start(); !errors.As(err, &e) || e.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429 past the pending cap, got %v", err)
+	}
+	if server.Pending.Len() != 2 {
+		t.Errorf("%d handshakes pending", server.Pending.Len())
+	}
+	// Other identities aren't held up.
+	if err := client.post(ctx, "/start", &StartRequest{Identity: "bob", Group: grp.Label, A: srp.NewClientStd(grp, big.NewInt(0)).EphemeralPublicHex()}, nil); err != nil {
+		t.Errorf("start for another identity failed: %s", err)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,96 @@
+package srphttp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoSession is returned by a SessionStore for an unknown or expired session.
+var ErrNoSession = errors.New("no such session")
+
+// SessionStore keeps the server's state for a verified session for as long as
+// the session is in use. The state is opaque
+// and contains secrets, so stores outside the process should protect it.
+type SessionStore interface {
+	// Save stores state under id, replacing anything already there.
+	Save(ctx context.Context, id string, state []byte) error
+	// Load returns the state saved under id or ErrNoSession.
+	Load(ctx context.Context, id string) ([]byte, error)
+	// Delete forgets id. Deleting an unknown session is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemorySessionStore is a SessionStore that keeps sessions in memory.
+// Sessions expire a fixed time after they were last saved.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]memorySession
+	sweepAt  int // sweep expired sessions once there are this many
+}
+
+type memorySession struct {
+	state   []byte
+	expires time.Time
+}
+
+var _ SessionStore = &MemorySessionStore{} //nolint:exhaustruct
+
+// NewMemorySessionStore returns an empty MemorySessionStore whose sessions last for ttl.
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{ttl: ttl, sessions: make(map[string]memorySession), sweepAt: 64}
+}
+
+// Save implements SessionStore.
+func (ms *MemorySessionStore) Save(_ context.Context, id string, state []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now()
+	if len(ms.sessions) >= ms.sweepAt {
+		for k, sess := range ms.sessions {
+			if now.After(sess.expires) {
+				delete(ms.sessions, k)
+			}
+		}
+		// Sweeping is linear, so don't do it again until the map has doubled.
+		ms.sweepAt = 2 * len(ms.sessions)
+		if ms.sweepAt < 64 {
+			ms.sweepAt = 64
+		}
+	}
+	ms.sessions[id] = memorySession{
+		state:   append([]byte(nil), state...),
+		expires: now.Add(ms.ttl),
+	}
+	return nil
+}
+
+// Load implements SessionStore.
+func (ms *MemorySessionStore) Load(_ context.Context, id string) ([]byte, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	sess, ok := ms.sessions[id]
+	if !ok {
+		return nil, ErrNoSession
+	}
+	if time.Now().After(sess.expires) {
+		delete(ms.sessions, id)
+		return nil, ErrNoSession
+	}
+	return append([]byte(nil), sess.state...), nil
+}
+
+// Delete implements SessionStore.
+func (ms *MemorySessionStore) Delete(_ context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.sessions, id)
+	return nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,333 @@
+package srphttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/1Password/srp"
+)
+
+/*
+Once a session is verified, every later request is authenticated with an HMAC
+under a key derived from the session key. The client's SigningTransport adds
+three headers to each request,
+
+	X-SRP-Session:   the session ID
+	X-SRP-Counter:   a counter, starting at 1 and increasing with each request
+	X-SRP-Signature: base64 of HMAC-SHA256(K', canonical request)
+
+where K' is 32 bytes of ExportKeyingMaterial with the label "SRP request MAC v1"
+and no context, and the canonical request is
+
+	SRP-HMAC-SHA256 v1
+	<session ID>
+	<counter>
+	<method>
+	<escaped path>[?<raw query>]
+	<lower case header name>:<values joined by ",">   (one line per signed header)
+	<hex SHA-256 of the body>
+
+each line ending with "\n". Server.Authenticate checks the signature and that the
+counter hasn't been seen before for the session, so a request can't be
+replayed, and passes the request on with its AuthInfo in the context. By default
+each counter must be higher than the last. Requests sent at once may arrive out
+of order, so Server.CounterWindow can allow a counter that far below the highest
+seen, if it is new; anything further behind is refused.
+
+Both sides must sign the same headers, in the same order.
+*/
+
+// Headers used for signed requests.
+const (
+	SessionHeader   = "X-SRP-Session"
+	CounterHeader   = "X-SRP-Counter"
+	SignatureHeader = "X-SRP-Signature"
+)
+
+const (
+	requestMACLabel  = "SRP request MAC v1"
+	signatureVersion = "SRP-HMAC-SHA256 v1"
+)
+
+// maxSignedBodySize limits the size of the bodies of signed requests.
+const maxSignedBodySize = 10 << 20
+
+// MaxCounterWindow is the largest Server.CounterWindow.
+const MaxCounterWindow = 64
+
+// requestMACKey derives the request signing key for a session.
+func requestMACKey(s *srp.SRP) ([]byte, error) {
+	return s.ExportKeyingMaterial(requestMACLabel, nil, sha256.Size)
+}
+
+// signRequest returns the signature of the request described by the arguments.
+func signRequest(macKey []byte, session string, counter uint64, r *http.Request, headers []string, body []byte) []byte {
+	var b strings.Builder
+	b.WriteString(signatureVersion + "\n")
+	b.WriteString(session + "\n")
+	b.WriteString(strconv.FormatUint(counter, 10) + "\n")
+	b.WriteString(strings.ToUpper(r.Method) + "\n")
+	b.WriteString(r.URL.EscapedPath())
+	if r.URL.RawQuery != "" {
+		b.WriteString("?" + r.URL.RawQuery)
+	}
+	b.WriteString("\n")
+	for _, name := range headers {
+		var value string
+		if strings.EqualFold(name, "Host") {
+			value = r.Host
+			if value == "" {
+				value = r.URL.Host
+			}
+		} else {
+			value = strings.Join(r.Header.Values(name), ",")
+		}
+		b.WriteString(strings.ToLower(name) + ":" + strings.TrimSpace(value) + "\n")
+	}
+	bodyHash := sha256.Sum256(body)
+	b.WriteString(hex.EncodeToString(bodyHash[:]))
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write([]byte(b.String()))
+	return mac.Sum(nil)
+}
+
+// SigningTransport is an http.RoundTripper that signs requests for a verified session.
+//
+// Requests may be sent concurrently, but the server refuses any that arrive after
+// one with a higher counter unless its CounterWindow is set, in which case no more
+// than that many may be in flight at once.
+type SigningTransport struct {
+	base    http.RoundTripper
+	session string
+	macKey  []byte
+	headers []string
+
+	mu      sync.Mutex
+	counter uint64
+}
+
+var _ http.RoundTripper = &SigningTransport{} //nolint:exhaustruct
+
+// NewSigningTransport returns a SigningTransport for sess that sends requests
+// with base, signing the named headers along with the rest of each request.
+// If base is nil, http.DefaultTransport is used.
+func NewSigningTransport(sess *Session, base http.RoundTripper, headers ...string) (*SigningTransport, error) {
+	macKey, err := requestMACKey(sess.SRP)
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &SigningTransport{
+		base:    base,
+		session: sess.ID,
+		macKey:  macKey,
+		headers: append([]string(nil), headers...),
+	}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (st *SigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A RoundTripper mustn't modify the request it is given.
+	signed := req.Clone(req.Context())
+	signed.Body = ioutil.NopCloser(bytes.NewReader(body))
+	signed.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	signed.ContentLength = int64(len(body))
+
+	st.mu.Lock()
+	st.counter++
+	counter := st.counter
+	st.mu.Unlock()
+
+	sig := signRequest(st.macKey, st.session, counter, signed, st.headers, body)
+	signed.Header.Set(SessionHeader, st.session)
+	signed.Header.Set(CounterHeader, strconv.FormatUint(counter, 10))
+	signed.Header.Set(SignatureHeader, base64.StdEncoding.EncodeToString(sig))
+	return st.base.RoundTrip(signed)
+}
+
+// AuthInfo describes the session that a signed request was made in.
+type AuthInfo struct {
+	Session    string
+	Identity   string
+	Credential string
+}
+
+type authInfoKey struct{}
+
+// AuthFromContext returns the AuthInfo that Server.Authenticate put in the
+// context of a request.
+func AuthFromContext(ctx context.Context) (AuthInfo, bool) {
+	info, ok := ctx.Value(authInfoKey{}).(AuthInfo)
+	return info, ok
+}
+
+/*
+Authenticate returns middleware that passes on only requests signed by a
+SigningTransport for a verified session, with the same headers. It rejects
+requests whose counter isn't higher than the highest seen for the session,
+unless s.CounterWindow allows it and it hasn't been seen before.
+
+Counters are checked and saved under a lock for the session, held only within
+s. If several servers share a SessionStore, a request replayed to two of them
+at the same moment may be accepted by both.
+*/
+func (s *Server) Authenticate(next http.Handler, headers ...string) http.Handler {
+	headers = append([]string(nil), headers...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, err := s.checkSignature(w, r, headers)
+		if err != nil {
+			var he *httpError
+			if !errors.As(err, &he) {
+				he = &httpError{status: http.StatusInternalServerError, msg: "internal error"}
+			}
+			_ = writeJSON(w, he.status, &ErrorResponse{Error: he.msg})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authInfoKey{}, info)))
+	})
+}
+
+func (s *Server) checkSignature(w http.ResponseWriter, r *http.Request, headers []string) (AuthInfo, error) {
+	id := r.Header.Get(SessionHeader)
+	counter, err := strconv.ParseUint(r.Header.Get(CounterHeader), 10, 64)
+	if id == "" || err != nil || counter == 0 {
+		return AuthInfo{}, errAuthFailed
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Header.Get(SignatureHeader))
+	if err != nil {
+		return AuthInfo{}, errAuthFailed
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodySize))
+		if err != nil {
+			return AuthInfo{}, &httpError{status: http.StatusRequestEntityTooLarge, msg: "request too large"}
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	ctx := r.Context()
+	unlock := s.sessionLocks.lock(id)
+	defer unlock()
+	sess, server, err := s.load(ctx, id)
+	if err != nil {
+		return AuthInfo{}, err
+	}
+	if !sess.Verified {
+		return AuthInfo{}, errAuthFailed
+	}
+	macKey, err := requestMACKey(server)
+	if err != nil {
+		return AuthInfo{}, fmt.Errorf("no key for verified session: %w", err)
+	}
+	if !hmac.Equal(sig, signRequest(macKey, id, counter, r, headers, body)) {
+		return AuthInfo{}, errAuthFailed
+	}
+	if !sess.acceptCounter(counter, s.CounterWindow) {
+		return AuthInfo{}, &httpError{status: http.StatusUnauthorized, msg: "replayed request"}
+	}
+	if err := s.save(ctx, id, sess, server); err != nil {
+		return AuthInfo{}, err
+	}
+	return AuthInfo{Session: id, Identity: sess.Identity, Credential: sess.Credential}, nil
+}
+
+// acceptCounter reports whether counter is new for sess and no more than window
+// below the highest seen, and if it is, records it.
+func (sess *session) acceptCounter(counter uint64, window int) bool {
+	if window < 0 {
+		window = 0
+	} else if window > MaxCounterWindow {
+		window = MaxCounterWindow
+	}
+	switch {
+	case counter > sess.Counter:
+		if shift := counter - sess.Counter; shift < MaxCounterWindow {
+			sess.Seen <<= shift
+		} else {
+			sess.Seen = 0
+		}
+		sess.Seen |= 1
+		sess.Counter = counter
+		return true
+	case sess.Counter-counter >= uint64(window):
+		return false
+	default:
+		bit := uint64(1) << (sess.Counter - counter)
+		if sess.Seen&bit != 0 {
+			return false
+		}
+		sess.Seen |= bit
+		return true
+	}
+}
+
+// keyedMutex is a set of mutexes by key, each existing only while it is held or waited for.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks key's mutex and returns the function that unlocks it.
+func (km *keyedMutex) lock(key string) (unlock func()) {
+	km.mu.Lock()
+	if km.locks == nil {
+		km.locks = make(map[string]*keyedLock)
+	}
+	l := km.locks[key]
+	if l == nil {
+		l = &keyedLock{}
+		km.locks[key] = l
+	}
+	l.refs++
+	km.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		km.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(km.locks, key)
+		}
+		km.mu.Unlock()
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
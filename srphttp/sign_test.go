@@ -0,0 +1,178 @@
+package srphttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+// recordingTransport remembers the last request it sent.
+type recordingTransport struct {
+	base http.RoundTripper
+	last *http.Request
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.last = req
+	return rt.base.RoundTrip(req)
+}
+
+func TestSignedRequests(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer(srp.NewMemoryStore(), NewMemorySessionStore(time.Minute))
+	server.CounterWindow = MaxCounterWindow
+	mux := http.NewServeMux()
+	mux.Handle("/", server.Handler())
+	mux.Handle("/api/", server.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, _ := AuthFromContext(r.Context())
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write([]byte(info.Identity + ":" + string(body)))
+	}), "Content-Type"))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewClient(ts.URL, ts.Client())
+	client.GroupID = srp.RFC5054Group2048
+	if err := client.Enroll(ctx, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	sess, err := client.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &recordingTransport{base: ts.Client().Transport}
+	transport, err := NewSigningTransport(sess, recorder, "Content-Type")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		resp, err := signed.Post(ts.URL+"/api/thing?x=1", "text/plain", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "alice:hello" {
+			t.Errorf("signed request %d failed: %d %s", i, resp.StatusCode, body)
+		}
+	}
+
+	// Concurrent requests are all accepted, whatever order they arrive in.
+	concurrent := &http.Client{Transport: &SigningTransport{
+		base: ts.Client().Transport, session: transport.session, macKey: transport.macKey,
+		headers: transport.headers, counter: 100,
+	}}
+	const n = 16
+	statuses := make(chan int, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			resp, err := concurrent.Post(ts.URL+"/api/thing", "text/plain", strings.NewReader("hi"))
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if status := <-statuses; status != http.StatusOK {
+			t.Errorf("concurrent signed request got %d", status)
+		}
+	}
+
+	send := func(req *http.Request) int {
+		t.Helper()
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	replay := func(body string) *http.Request {
+		req := recorder.last.Clone(ctx)
+		req.RequestURI = ""
+		req.Body = ioutil.NopCloser(strings.NewReader(body))
+		req.ContentLength = int64(len(body))
+		return req
+	}
+
+	if status := send(replay("hello")); status != http.StatusUnauthorized {
+		t.Errorf("replayed request got %d", status)
+	}
+
+	// A fresh counter doesn't help without the key.
+	tampered := replay("goodbye")
+	tampered.Header.Set(CounterHeader, "100")
+	if status := send(tampered); status != http.StatusUnauthorized {
+		t.Errorf("tampered request got %d", status)
+	}
+
+	unsigned, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/thing", nil)
+	if status := send(unsigned); status != http.StatusUnauthorized {
+		t.Errorf("unsigned request got %d", status)
+	}
+}
+
+func TestAcceptCounter(t *testing.T) {
+	type counterStep struct {
+		counter uint64
+		want    bool
+	}
+	for _, tc := range []struct {
+		window int
+		steps  []counterStep
+	}{
+		{0, []counterStep{
+			{5, true},
+			{5, false},
+			{3, false}, // new, but late
+			{6, true},
+			{100, true},
+			{99, false},
+		}},
+		{MaxCounterWindow, []counterStep{
+			{5, true},
+			{5, false},
+			{3, true}, // late, but new
+			{3, false},
+			{4, true},
+			{100, true},
+			{37, true},  // 63 behind
+			{36, false}, // 64 behind
+			{37, false},
+			{99, true},
+			{200, true},
+			{150, true},
+			{100, false}, // seen, and now too far behind anyway
+		}},
+		{2, []counterStep{
+			{10, true},
+			{9, true},
+			{8, false}, // 2 behind
+			{11, true},
+		}},
+	} {
+		var sess session
+		for _, step := range tc.steps {
+			if got := sess.acceptCounter(step.counter, tc.window); got != step.want {
+				t.Errorf("window %d: acceptCounter(%d) = %v, want %v", tc.window, step.counter, got, step.want)
+			}
+		}
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
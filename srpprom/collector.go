@@ -0,0 +1,214 @@
+package srpprom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+// Buckets are the upper bounds, in seconds, of the histograms' buckets.
+var Buckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// Collector is an srp.Observer that keeps metrics. It is safe for concurrent use.
+type Collector struct {
+	mu         sync.Mutex
+	ephemeral  map[string]*histogram // by labels
+	key        map[string]*histogram
+	proofCheck map[string]*histogram
+	proofs     map[string]uint64
+	rejections map[string]uint64
+	pools      []*srp.EphemeralPool
+}
+
+var (
+	_ srp.Observer = &Collector{} //nolint:exhaustruct
+	_ http.Handler = &Collector{} //nolint:exhaustruct
+)
+
+// NewCollector returns a Collector with no metrics yet.
+func NewCollector() *Collector {
+	return &Collector{
+		ephemeral:  make(map[string]*histogram),
+		key:        make(map[string]*histogram),
+		proofCheck: make(map[string]*histogram),
+		proofs:     make(map[string]uint64),
+		rejections: make(map[string]uint64),
+	}
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	secs := d.Seconds()
+	i := sort.SearchFloat64s(Buckets, secs)
+	h.counts[i]++
+	h.sum += secs
+}
+
+func (c *Collector) observe(m map[string]*histogram, info srp.HandshakeInfo, d time.Duration) {
+	labels := baseLabels(info)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := m[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(Buckets)+1)}
+		m[labels] = h
+	}
+	h.observe(d)
+}
+
+// WatchPool adds the hits, misses and ready pairs of pool to the metrics.
+func (c *Collector) WatchPool(pool *srp.EphemeralPool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pools = append(c.pools, pool)
+}
+
+// HandshakeStarted implements srp.Observer.
+func (c *Collector) HandshakeStarted(info srp.HandshakeInfo, d time.Duration) {
+	c.observe(c.ephemeral, info, d)
+}
+
+// KeyDerived implements srp.Observer.
+func (c *Collector) KeyDerived(info srp.HandshakeInfo, d time.Duration) {
+	c.observe(c.key, info, d)
+}
+
+// ProofChecked implements srp.Observer.
+func (c *Collector) ProofChecked(info srp.HandshakeInfo, ok bool, d time.Duration) {
+	c.observe(c.proofCheck, info, d)
+	result := "bad"
+	if ok {
+		result = "good"
+	}
+	labels := baseLabels(info) + `,result="` + result + `"`
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.proofs[labels]++
+}
+
+// Rejected implements srp.Observer.
+func (c *Collector) Rejected(info srp.HandshakeInfo, reason srp.RejectReason) {
+	labels := baseLabels(info) + `,reason="` + escape(string(reason)) + `"`
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rejections[labels]++
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	c.mu.Lock()
+	writeHistograms(bw, "srp_ephemeral_seconds", "Time to compute A or B.", c.ephemeral)
+	writeHistograms(bw, "srp_key_seconds", "Time to compute the session key.", c.key)
+	writeHistograms(bw, "srp_proof_check_seconds", "Time to check the other party's proof.", c.proofCheck)
+	writeCounters(bw, "srp_proofs_total", "Proofs checked, by result.", c.proofs)
+	writeCounters(bw, "srp_rejections_total", "Values from the other party rejected, by reason.", c.rejections)
+	if len(c.pools) > 0 {
+		hits, misses, ready := make(map[string]uint64), make(map[string]uint64), make(map[string]uint64)
+		for _, pool := range c.pools {
+			labels := `group="` + escape(pool.Group().Label) + `"`
+			stats := pool.Stats()
+			hits[labels] += stats.Hits
+			misses[labels] += stats.Misses
+			ready[labels] += uint64(stats.Ready)
+		}
+		writeCounters(bw, "srp_ephemeral_pool_hits_total", "Servers that took a pair from an ephemeral pool.", hits)
+		writeCounters(bw, "srp_ephemeral_pool_misses_total", "Servers that found an ephemeral pool empty.", misses)
+		writeMetrics(bw, "srp_ephemeral_pool_ready", "gauge", "Pairs ready in ephemeral pools.", ready)
+	}
+	c.mu.Unlock()
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+func writeHistograms(w *bufio.Writer, name, help string, m map[string]*histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // so that output is stable
+	for _, labels := range keys {
+		h := m[labels]
+		var cumulative uint64
+		for i, le := range Buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(le), cumulative)
+		}
+		cumulative += h.counts[len(Buckets)]
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, cumulative)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, cumulative)
+	}
+}
+
+func writeCounters(w *bufio.Writer, name, help string, m map[string]uint64) {
+	writeMetrics(w, name, "counter", help, m)
+}
+
+func writeMetrics(w *bufio.Writer, name, typ, help string, m map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, labels := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, labels, m[labels])
+	}
+}
+
+func baseLabels(info srp.HandshakeInfo) string {
+	role := "client"
+	if info.Server {
+		role = "server"
+	}
+	return `group="` + escape(info.Group) + `",role="` + role + `"`
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,110 @@
+package srpprom
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	srp.SetObserver(c)
+	defer srp.SetObserver(nil)
+
+	grp := srp.KnownGroups[srp.RFC5054Group2048]
+	x := big.NewInt(0x5eed)
+	v, _ := srp.NewClientStd(grp, x).Verifier()
+	client := srp.NewClientStd(grp, x)
+	server := srp.NewServerStd(grp, v)
+	if err := server.SetOthersPublic(client.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetOthersPublic(server.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = server.Key()
+	_, _ = client.Key()
+	m, _ := server.M([]byte("salt"), "alice")
+	if !client.GoodServerProof([]byte("salt"), "alice", m) {
+		t.Fatal("bad server proof")
+	}
+	proof, _ := client.ClientProof()
+	if server.GoodClientProof(append([]byte{0}, proof...)) {
+		t.Fatal("accepted bad client proof")
+	}
+	if err := srp.NewServerStd(grp, v).SetOthersPublic(big.NewInt(0)); err == nil {
+		t.Fatal("accepted zero A")
+	}
+	c.KeyDerived(srp.HandshakeInfo{Group: `we"ird`}, 2*time.Second)
+
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE srp_ephemeral_seconds histogram\n",
+		`srp_ephemeral_seconds_count{group="` + grp.Label + `",role="server"} 2` + "\n",
+		`srp_ephemeral_seconds_count{group="` + grp.Label + `",role="client"} 2` + "\n", // one made the verifier
+		`srp_key_seconds_bucket{group="` + grp.Label + `",role="client",le="+Inf"} 1` + "\n",
+		`srp_key_seconds_bucket{group="we\"ird",role="client",le="1"} 0` + "\n",
+		`srp_key_seconds_sum{group="we\"ird",role="client"} 2` + "\n",
+		`srp_proofs_total{group="` + grp.Label + `",role="client",result="good"} 1` + "\n",
+		`srp_proofs_total{group="` + grp.Label + `",role="server",result="bad"} 1` + "\n",
+		`srp_rejections_total{group="` + grp.Label + `",role="server",reason="invalid_public"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q", want)
+		}
+	}
+	if t.Failed() {
+		t.Log(out)
+	}
+}
+
+func TestWatchPool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	grp := srp.KnownGroups[srp.RFC5054Group3072]
+	pool, err := srp.NewEphemeralPool(ctx, grp, &srp.EphemeralPoolConfig{Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cancel()
+		<-pool.Done()
+	}()
+	c := NewCollector()
+	c.WatchPool(pool)
+	v, _ := srp.NewClientStd(grp, big.NewInt(0x5eed)).Verifier()
+	_ = srp.NewServerStd(grp, v)
+
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	stats := pool.Stats()
+	for _, want := range []string{
+		"# TYPE srp_ephemeral_pool_ready gauge\n",
+		fmt.Sprintf(`srp_ephemeral_pool_hits_total{group="%s"} %d`+"\n", grp.Label, stats.Hits),
+		fmt.Sprintf(`srp_ephemeral_pool_misses_total{group="%s"} %d`+"\n", grp.Label, stats.Misses),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q", want)
+		}
+	}
+	if stats.Hits+stats.Misses != 1 {
+		t.Errorf("got %+v, want one hit or miss", stats)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,28 @@
+/*
+Package srpprom keeps metrics about SRP handshakes and exposes them in the
+Prometheus text format, without depending on the Prometheus client libraries.
+
+	c := srpprom.NewCollector()
+	srp.SetObserver(c)
+	http.Handle("/metrics", c)
+
+The metrics, each labeled with the group and the role (client or server), are
+
+	srp_ephemeral_seconds      histogram of the time to compute A or B
+	srp_key_seconds            histogram of the time to compute the key
+	srp_proof_check_seconds    histogram of the time to check a proof
+	srp_proofs_total           counter of proofs checked, by result (good or bad)
+	srp_rejections_total       counter of rejected values, by reason
+
+and for each srp.EphemeralPool passed to WatchPool, labeled with the group only,
+
+	srp_ephemeral_pool_hits_total    counter of servers that took a pair
+	srp_ephemeral_pool_misses_total  counter of servers that found the pool empty
+	srp_ephemeral_pool_ready         gauge of pairs ready
+*/
+package srpprom
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
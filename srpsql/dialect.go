@@ -0,0 +1,64 @@
+package srpsql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect is the flavor of SQL spoken by the database behind a Store.
+type Dialect int
+
+// Supported dialects.
+const (
+	SQLite Dialect = iota + 1
+	Postgres
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	case Postgres:
+		return "postgres"
+	default:
+		return fmt.Sprintf("Dialect(%d)", int(d))
+	}
+}
+
+func (d Dialect) isValid() bool {
+	return d == SQLite || d == Postgres
+}
+
+// bytesType is the column type for binary data.
+func (d Dialect) bytesType() string {
+	if d == Postgres {
+		return "BYTEA"
+	}
+	return "BLOB"
+}
+
+// rebind rewrites the "?" placeholders in query into the form the dialect expects.
+// Queries in this package never contain a literal "?" other than as a placeholder.
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,16 @@
+/*
+Package srpsql provides an srp.VerifierStore backed by database/sql.
+
+The caller supplies the *sql.DB (and so the driver) along with the SQL dialect
+spoken by that database. Store.Migrate brings the schema up to date and must be
+called before the store is used.
+
+Updates use optimistic concurrency: each record carries a version, and an update
+only succeeds if the stored version is the one the caller read.
+*/
+package srpsql
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,163 @@
+package srpsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migration is one step in the evolution of the schema.
+// Steps are applied in order, each in its own transaction, and are never edited
+// once released. Changes to the schema are made by appending new steps.
+type migration struct {
+	version     int
+	description string
+	statements  func(d Dialect) []string
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create verifier table",
+		statements: func(d Dialect) []string {
+			return []string{
+				fmt.Sprintf(`CREATE TABLE srp_verifiers (
+					identity       TEXT PRIMARY KEY,
+					salt           %[1]s NOT NULL,
+					group_id       INTEGER NOT NULL,
+					kdf_alg        TEXT NOT NULL,
+					kdf_iterations BIGINT NOT NULL,
+					verifier       %[1]s NOT NULL,
+					version        BIGINT NOT NULL
+				)`, d.bytesType()),
+			}
+		},
+	},
+	{
+		// Rebuilding the table is the only way SQLite has to relax a NOT NULL constraint.
+		version:     2,
+		description: "add sealed verifiers",
+		statements: func(d Dialect) []string {
+			return []string{
+				fmt.Sprintf(`CREATE TABLE srp_verifiers_new (
+					identity        TEXT PRIMARY KEY,
+					salt            %[1]s NOT NULL,
+					group_id        INTEGER NOT NULL,
+					kdf_alg         TEXT NOT NULL,
+					kdf_iterations  BIGINT NOT NULL,
+					verifier        %[1]s,
+					sealed_verifier %[1]s,
+					version         BIGINT NOT NULL
+				)`, d.bytesType()),
+				`INSERT INTO srp_verifiers_new
+					(identity, salt, group_id, kdf_alg, kdf_iterations, verifier, version)
+					SELECT identity, salt, group_id, kdf_alg, kdf_iterations, verifier, version
+					FROM srp_verifiers`,
+				`DROP TABLE srp_verifiers`,
+				`ALTER TABLE srp_verifiers_new RENAME TO srp_verifiers`,
+			}
+		},
+	},
+	{
+		// Existing records become the primary credential of their identity.
+		version:     3,
+		description: "add credentials",
+		statements: func(d Dialect) []string {
+			return []string{
+				fmt.Sprintf(`CREATE TABLE srp_verifiers_new (
+					identity        TEXT NOT NULL,
+					credential      TEXT NOT NULL,
+					salt            %[1]s NOT NULL,
+					group_id        INTEGER NOT NULL,
+					kdf_alg         TEXT NOT NULL,
+					kdf_iterations  BIGINT NOT NULL,
+					verifier        %[1]s,
+					sealed_verifier %[1]s,
+					revoked_at      BIGINT,
+					version         BIGINT NOT NULL,
+					PRIMARY KEY (identity, credential)
+				)`, d.bytesType()),
+				`INSERT INTO srp_verifiers_new
+					(identity, credential, salt, group_id, kdf_alg, kdf_iterations, verifier, sealed_verifier, version)
+					SELECT identity, '', salt, group_id, kdf_alg, kdf_iterations, verifier, sealed_verifier, version
+					FROM srp_verifiers`,
+				`DROP TABLE srp_verifiers`,
+				`ALTER TABLE srp_verifiers_new RENAME TO srp_verifiers`,
+			}
+		},
+	},
+}
+
+// SchemaVersion is the schema version that this package expects.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Migrate brings the database schema up to date, applying any migrations
+// that have not yet been applied. It is safe to call on every start up.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS srp_schema_migrations (
+		version     INTEGER PRIMARY KEY,
+		description TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	current, err := s.CurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current > SchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", current, SchemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := s.apply(ctx, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+// CurrentVersion returns the schema version of the database, which is 0
+// if no migrations have been applied.
+func (s *Store) CurrentVersion(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	row := s.db.QueryRowContext(ctx, `SELECT MAX(version) FROM srp_schema_migrations`)
+	if err := row.Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+func (s *Store) apply(ctx context.Context, m migration) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, stmt := range m.statements(s.dialect) {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.ExecContext(ctx,
+		s.dialect.rebind(`INSERT INTO srp_schema_migrations (version, description) VALUES (?, ?)`),
+		m.version, m.description); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
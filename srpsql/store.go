@@ -0,0 +1,193 @@
+package srpsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/1Password/srp"
+)
+
+// Store is an srp.VerifierStore kept in an SQL database.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+var (
+	_ srp.VerifierStore  = &Store{} //nolint:exhaustruct
+	_ srp.VerifierLister = &Store{} //nolint:exhaustruct
+)
+
+// NewStore returns a Store using db, which must speak dialect.
+// Call Migrate before using the store.
+func NewStore(db *sql.DB, dialect Dialect) (*Store, error) {
+	if db == nil {
+		return nil, fmt.Errorf("nil database")
+	}
+	if !dialect.isValid() {
+		return nil, fmt.Errorf("unsupported dialect %s", dialect)
+	}
+	return &Store{db: db, dialect: dialect}, nil
+}
+
+// Lookup returns the record for the credential of identity or srp.ErrUnknownIdentity.
+func (s *Store) Lookup(ctx context.Context, identity, credential string) (*srp.VerifierRecord, error) {
+	return s.lookup(ctx, s.db, identity, credential)
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) lookup(ctx context.Context, q queryer, identity, credential string) (*srp.VerifierRecord, error) {
+	var (
+		rec        srp.VerifierRecord
+		iterations int64
+		v, sealed  []byte
+		revokedAt  sql.NullInt64
+	)
+	row := q.QueryRowContext(ctx, s.dialect.rebind(`SELECT
+		identity, credential, salt, group_id, kdf_alg, kdf_iterations,
+		verifier, sealed_verifier, revoked_at, version
+		FROM srp_verifiers WHERE identity = ? AND credential = ?`), identity, credential)
+	err := row.Scan(&rec.Identity, &rec.Credential, &rec.Salt, &rec.GroupID, &rec.KDF.Alg, &iterations,
+		&v, &sealed, &revokedAt, &rec.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, srp.ErrUnknownIdentity
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up verifier: %w", err)
+	}
+	rec.KDF.Iterations = uint32(iterations)
+	if v != nil {
+		rec.Verifier = new(big.Int).SetBytes(v)
+	}
+	rec.SealedVerifier = sealed
+	if revokedAt.Valid {
+		rec.RevokedAt = time.Unix(0, revokedAt.Int64)
+	}
+	return &rec, nil
+}
+
+// Enroll stores a new record with version 1, setting rec.Version accordingly.
+// It returns srp.ErrIdentityExists if the identity already has a record for the credential.
+func (s *Store) Enroll(ctx context.Context, rec *srp.VerifierRecord) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO srp_verifiers
+		(identity, credential, salt, group_id, kdf_alg, kdf_iterations,
+		verifier, sealed_verifier, revoked_at, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1)
+		ON CONFLICT DO NOTHING`),
+		rec.Identity, rec.Credential, rec.Salt, rec.GroupID, rec.KDF.Alg, int64(rec.KDF.Iterations),
+		verifierBytes(rec), rec.SealedVerifier, revokedAt(rec))
+	if err != nil {
+		return fmt.Errorf("failed to enroll verifier: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to enroll verifier: %w", err)
+	}
+	if n == 0 {
+		return srp.ErrIdentityExists
+	}
+	rec.Version = 1
+	return nil
+}
+
+// Update replaces the stored record for rec.Identity and rec.Credential if its stored version
+// equals rec.Version. See srp.VerifierStore.
+func (s *Store) Update(ctx context.Context, rec *srp.VerifierRecord) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, s.dialect.rebind(`UPDATE srp_verifiers SET
+		salt = ?, group_id = ?, kdf_alg = ?, kdf_iterations = ?,
+		verifier = ?, sealed_verifier = ?, revoked_at = ?, version = version + 1
+		WHERE identity = ? AND credential = ? AND version = ?`),
+		rec.Salt, rec.GroupID, rec.KDF.Alg, int64(rec.KDF.Iterations),
+		verifierBytes(rec), rec.SealedVerifier, revokedAt(rec),
+		rec.Identity, rec.Credential, rec.Version)
+	if err != nil {
+		return fmt.Errorf("failed to update verifier: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update verifier: %w", err)
+	}
+	if n == 0 {
+		// Either there is nothing to update or someone got there first.
+		if _, err := s.Lookup(ctx, rec.Identity, rec.Credential); err != nil {
+			return err
+		}
+		return srp.ErrVersionConflict
+	}
+	rec.Version++
+	return nil
+}
+
+// Identities returns all enrolled identities in sorted order.
+func (s *Store) Identities(ctx context.Context) ([]string, error) {
+	ids, err := s.queryStrings(ctx, `SELECT DISTINCT identity FROM srp_verifiers ORDER BY identity`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	return ids, nil
+}
+
+// Credentials returns the credential names of identity in sorted order.
+func (s *Store) Credentials(ctx context.Context, identity string) ([]string, error) {
+	names, err := s.queryStrings(ctx, s.dialect.rebind(
+		`SELECT credential FROM srp_verifiers WHERE identity = ? ORDER BY credential`), identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	return names, nil
+}
+
+// queryStrings returns the single string column of every row from query.
+func (s *Store) queryStrings(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var str string
+		if err := rows.Scan(&str); err != nil {
+			return nil, err
+		}
+		result = append(result, str)
+	}
+	return result, rows.Err()
+}
+
+// revokedAt returns the revocation time in nanoseconds since the epoch,
+// or nil if the record hasn't been revoked.
+func revokedAt(rec *srp.VerifierRecord) interface{} {
+	if !rec.IsRevoked() {
+		return nil
+	}
+	return rec.RevokedAt.UnixNano()
+}
+
+// verifierBytes returns the verifier as bytes, or nil if the record is sealed.
+func verifierBytes(rec *srp.VerifierRecord) []byte {
+	if rec.Verifier == nil {
+		return nil
+	}
+	return rec.Verifier.Bytes()
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
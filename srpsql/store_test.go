@@ -0,0 +1,252 @@
+package srpsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/1Password/srp"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "verifiers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	store, err := NewStore(db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("migration failed: %s", err)
+	}
+	return store
+}
+
+func sampleRecord() *srp.VerifierRecord {
+	return &srp.VerifierRecord{
+		Identity: "fred@fred.example",
+		Salt:     []byte{0xbe, 0xb2, 0x53, 0x79},
+		GroupID:  srp.RFC5054Group3072,
+		KDF:      srp.KDFParams{Alg: "PBES2g-HS256", Iterations: 100000},
+		Verifier: big.NewInt(0x0123456789),
+		Version:  0,
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	version, err := store.CurrentVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion() {
+		t.Errorf("schema version is %d, expected %d", version, SchemaVersion())
+	}
+
+	// Migrating again must be harmless
+	if err := store.Migrate(ctx); err != nil {
+		t.Errorf("second migration failed: %s", err)
+	}
+}
+
+func TestMigrateKeepsData(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "verifiers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store, _ := NewStore(db, SQLite)
+
+	// Bring the database up to version 1 only, and put a record in it
+	if _, err := db.Exec(`CREATE TABLE srp_schema_migrations (version INTEGER PRIMARY KEY, description TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.apply(ctx, migrations[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO srp_verifiers VALUES ('alice', x'beb2', 3, 'rfc5054', 1, x'0123', 7)`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migration failed: %s", err)
+	}
+	rec, err := store.Lookup(ctx, "alice", srp.PrimaryCredential)
+	if err != nil {
+		t.Fatalf("record lost in migration: %s", err)
+	}
+	if rec.Verifier.Int64() != 0x0123 || rec.Version != 7 || rec.GroupID != 3 {
+		t.Errorf("record changed in migration: %+v", rec)
+	}
+}
+
+func TestEnrollLookup(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	if _, err := store.Lookup(ctx, "nobody", srp.PrimaryCredential); !errors.Is(err, srp.ErrUnknownIdentity) {
+		t.Errorf("expected ErrUnknownIdentity, got %v", err)
+	}
+
+	rec := sampleRecord()
+	if err := store.Enroll(ctx, rec); err != nil {
+		t.Fatalf("enroll failed: %s", err)
+	}
+	if rec.Version != 1 {
+		t.Errorf("enrolled record has version %d", rec.Version)
+	}
+	if err := store.Enroll(ctx, sampleRecord()); !errors.Is(err, srp.ErrIdentityExists) {
+		t.Errorf("expected ErrIdentityExists, got %v", err)
+	}
+
+	got, err := store.Lookup(ctx, rec.Identity, rec.Credential)
+	if err != nil {
+		t.Fatalf("lookup failed: %s", err)
+	}
+	if got.Verifier.Cmp(rec.Verifier) != 0 ||
+		string(got.Salt) != string(rec.Salt) ||
+		got.GroupID != rec.GroupID ||
+		got.KDF != rec.KDF ||
+		got.Version != rec.Version {
+		t.Errorf("looked up record %+v doesn't match enrolled %+v", got, rec)
+	}
+}
+
+func TestUpdateConcurrency(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	if err := store.Update(ctx, sampleRecord()); !errors.Is(err, srp.ErrUnknownIdentity) {
+		t.Errorf("expected ErrUnknownIdentity updating missing record, got %v", err)
+	}
+	if err := store.Enroll(ctx, sampleRecord()); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := store.Lookup(ctx, "fred@fred.example", srp.PrimaryCredential)
+	second, _ := store.Lookup(ctx, "fred@fred.example", srp.PrimaryCredential)
+
+	first.Verifier = big.NewInt(42)
+	if err := store.Update(ctx, first); err != nil {
+		t.Fatalf("update failed: %s", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("updated record has version %d", first.Version)
+	}
+
+	second.Verifier = big.NewInt(43)
+	if err := store.Update(ctx, second); !errors.Is(err, srp.ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict on stale update, got %v", err)
+	}
+
+	got, _ := store.Lookup(ctx, "fred@fred.example", srp.PrimaryCredential)
+	if got.Verifier.Int64() != 42 {
+		t.Errorf("stale update was applied")
+	}
+}
+
+func TestSealedRecord(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	sealer, err := srp.NewVerifierSealer("k1", map[string][]byte{"k1": make([]byte, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := srp.NewSealedStore(store, sealer)
+	if err := sealed.Enroll(ctx, sampleRecord()); err != nil {
+		t.Fatalf("sealed enroll failed: %s", err)
+	}
+
+	raw, err := store.Lookup(ctx, "fred@fred.example", srp.PrimaryCredential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Verifier != nil || raw.SealedVerifier == nil {
+		t.Error("stored record isn't sealed")
+	}
+
+	rec, err := sealed.Lookup(ctx, "fred@fred.example", srp.PrimaryCredential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Verifier.Cmp(sampleRecord().Verifier) != 0 {
+		t.Error("opened verifier doesn't match")
+	}
+
+	ids, err := store.Identities(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "fred@fred.example" {
+		t.Errorf("unexpected identities %v", ids)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	if err := store.Enroll(ctx, sampleRecord()); err != nil {
+		t.Fatal(err)
+	}
+	device := sampleRecord()
+	device.Credential = "device-1"
+	if err := store.Enroll(ctx, device); err != nil {
+		t.Fatalf("couldn't enroll second credential: %s", err)
+	}
+
+	names, err := store.Credentials(ctx, "fred@fred.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != srp.PrimaryCredential || names[1] != "device-1" {
+		t.Errorf("unexpected credentials %q", names)
+	}
+	if ids, _ := store.Identities(ctx); len(ids) != 1 {
+		t.Errorf("identity listed %d times", len(ids))
+	}
+
+	revokedAt := time.Unix(1650000000, 0)
+	if err := srp.RevokeCredential(ctx, store, "fred@fred.example", "device-1", revokedAt); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Lookup(ctx, "fred@fred.example", "device-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.RevokedAt.Equal(revokedAt) {
+		t.Errorf("revoked at %s instead of %s", got.RevokedAt, revokedAt)
+	}
+	got, _ = store.Lookup(ctx, "fred@fred.example", srp.PrimaryCredential)
+	if got.IsRevoked() {
+		t.Error("revocation affected primary credential")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	q := "SELECT a FROM t WHERE b = ? AND c = ?"
+	if got := SQLite.rebind(q); got != q {
+		t.Errorf("sqlite rebind changed query: %s", got)
+	}
+	if got := Postgres.rebind(q); got != "SELECT a FROM t WHERE b = $1 AND c = $2" {
+		t.Errorf("postgres rebind gave %s", got)
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,139 @@
+package srp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+/*
+The server stores {I, s, v} long term, along with enough information for a client
+to re-derive x: which group was used and how x was derived from the password.
+VerifierRecord is that bundle, and VerifierStore is the interface to wherever
+a server keeps them.
+
+An identity may have several credentials, each with its own record: the primary
+password, printed recovery codes, high entropy keys held by particular devices,
+and so on. Records are keyed by identity and credential name together.
+*/
+
+// PrimaryCredential is the name of an identity's primary credential,
+// typically derived from its password.
+const PrimaryCredential = ""
+
+// KDFParams identifies the key derivation function a client used to derive x
+// and its cost parameters. The package itself does not interpret these; they are
+// stored so that a client can be told how to re-derive x.
+type KDFParams struct {
+	Alg        string
+	Iterations uint32
+}
+
+// VerifierRecord is the long term server side data for one credential of an identity.
+type VerifierRecord struct {
+	Identity   string
+	Credential string // PrimaryCredential or a name chosen by the client
+	Salt       []byte
+	GroupID    int // Key into KnownGroups
+	KDF        KDFParams
+	Verifier   *big.Int
+
+	// SealedVerifier holds the verifier encrypted by a VerifierSealer.
+	// Stored records have either Verifier or SealedVerifier set, but not both.
+	SealedVerifier []byte
+
+	// RevokedAt is when the credential was revoked, or the zero time if it hasn't been.
+	// Revoked records are kept so that their names aren't reused.
+	RevokedAt time.Time
+
+	// Version is maintained by the VerifierStore. It is incremented on
+	// every successful update and is used for optimistic concurrency.
+	Version int64
+
+	// fake is set on records made up by a FakeChallenger.
+	fake bool
+}
+
+// Group returns the Diffie-Hellman group named by the record or nil if
+// it is not among the KnownGroups.
+func (r *VerifierRecord) Group() *Group {
+	return KnownGroups[r.GroupID]
+}
+
+// IsFake returns whether the record was made up by a FakeChallenger.
+func (r *VerifierRecord) IsFake() bool {
+	return r.fake
+}
+
+// IsRevoked returns whether the credential has been revoked.
+func (r *VerifierRecord) IsRevoked() bool {
+	return !r.RevokedAt.IsZero()
+}
+
+// Validate checks that rec has an identity, that its names can be sealed for,
+// that it has exactly one of Verifier or SealedVerifier, and that it isn't a fake
+// record.
+func (r *VerifierRecord) Validate() error {
+	if r == nil {
+		return fmt.Errorf("nil verifier record")
+	}
+	if r.Identity == "" {
+		return fmt.Errorf("verifier record has no identity")
+	}
+	if _, err := credentialName(r.Identity, r.Credential); err != nil {
+		return err
+	}
+	if r.fake {
+		return fmt.Errorf("fake verifier records can't be stored")
+	}
+	hasV := r.Verifier != nil && r.Verifier.Sign() > 0
+	if hasV == (r.SealedVerifier != nil) {
+		return fmt.Errorf("verifier record must have exactly one of verifier or sealed verifier")
+	}
+	return nil
+}
+
+// Errors returned by VerifierStore implementations.
+var (
+	ErrUnknownIdentity = errors.New("unknown identity")
+	ErrIdentityExists  = errors.New("identity already enrolled")
+	ErrVersionConflict = errors.New("verifier record was modified concurrently")
+)
+
+// updateAttempts bounds how often we retry updating a record that is
+// being modified concurrently.
+const updateAttempts = 3
+
+// VerifierStore persists verifier records.
+type VerifierStore interface {
+	// Lookup returns the record for the credential of identity or ErrUnknownIdentity.
+	// Revoked records are returned like any other.
+	Lookup(ctx context.Context, identity, credential string) (*VerifierRecord, error)
+
+	// Enroll stores a new record. It returns ErrIdentityExists if
+	// the identity already has a record for the credential.
+	Enroll(ctx context.Context, rec *VerifierRecord) error
+
+	// Update replaces the stored record for rec.Identity and rec.Credential if and only if
+	// the stored Version equals rec.Version. On success rec.Version is
+	// incremented to match the stored record. If the versions differ
+	// ErrVersionConflict is returned and nothing is changed.
+	Update(ctx context.Context, rec *VerifierRecord) error
+}
+
+// VerifierLister is implemented by VerifierStores that can enumerate
+// the records they hold.
+type VerifierLister interface {
+	// Identities lists every identity with at least one record.
+	Identities(ctx context.Context) ([]string, error)
+
+	// Credentials lists the names of every credential of identity, including revoked ones.
+	Credentials(ctx context.Context, identity string) ([]string, error)
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
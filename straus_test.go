@@ -0,0 +1,116 @@
+package srp
+
+import (
+	rand "crypto/rand"
+	"math/big"
+	"testing"
+)
+
+/*
+The server's premaster secret, (Av^u)^b, can be rearranged as A^b * v^(ub) and
+computed with one run of squarings for both powers, as in Straus's (or
+Shamir's) method. jointExp does that, with 4 bit windows like big.Int's Exp, so
+that BenchmarkServerPremaster can compare it with what Key does.
+
+It loses in every group. ub is twice as long as b, so the joint run has as many
+squarings as Key's two Exps together, and more multiplications; and they are
+big.Int Mul and QuoRem, where Exp uses assembly Montgomery multiplication. A
+Montgomery multiplication written in Go was slower still than Mul and QuoRem.
+The client's (B - kg^x)^(a + ux) has only one power to begin with, and g^x
+already comes from the group's table.
+*/
+
+// jointExp sets z to x1^e1 * x2^e2 mod n, with e1 and e2 positive, and returns z.
+func jointExp(z, x1, e1, x2, e2, n *big.Int) *big.Int {
+	const window = 4
+	var t1, t2 [1 << window]*big.Int
+	for _, tc := range []struct {
+		t *[1 << window]*big.Int
+		x *big.Int
+	}{{&t1, x1}, {&t2, x2}} {
+		tc.t[1] = new(big.Int).Mod(tc.x, n)
+		for d := 2; d < len(tc.t); d++ {
+			tc.t[d] = new(big.Int).Mul(tc.t[d-1], tc.t[1])
+			tc.t[d].Mod(tc.t[d], n)
+		}
+	}
+
+	bitLen := e1.BitLen()
+	if l := e2.BitLen(); l > bitLen {
+		bitLen = l
+	}
+	acc, prod, q := big.NewInt(1), new(big.Int), new(big.Int)
+	mulMod := func(x *big.Int) {
+		prod.Mul(acc, x)
+		q.QuoRem(prod, n, acc)
+	}
+	for i := (bitLen + window - 1) / window * window; i > 0; i -= window {
+		for j := 0; j < window; j++ {
+			mulMod(acc)
+		}
+		d1, d2 := 0, 0
+		for j := window - 1; j >= 0; j-- {
+			d1 = d1<<1 | int(e1.Bit(i-window+j))
+			d2 = d2<<1 | int(e2.Bit(i-window+j))
+		}
+		if d1 != 0 {
+			mulMod(t1[d1])
+		}
+		if d2 != 0 {
+			mulMod(t2[d2])
+		}
+	}
+	return z.Set(acc)
+}
+
+// premasterInputs returns A, v, u and b of a server in grp.
+func premasterInputs(tb testing.TB, grp *Group) (A, v, u, b *big.Int) {
+	tb.Helper()
+	x := make([]byte, 32)
+	if _, err := rand.Read(x); err != nil {
+		tb.Fatal(err)
+	}
+	_, server := keyedPair(tb, std, grp, new(big.Int).SetBytes(x), nil)
+	return server.ephemeralPublicA, server.v, server.u, server.ephemeralPrivate
+}
+
+func TestJointExp(t *testing.T) {
+	grp := KnownGroups[RFC5054Group2048]
+	A, v, u, b := premasterInputs(t, grp)
+	want := new(big.Int).Exp(v, u, grp.n)
+	want.Mul(want, A)
+	want.Exp(want, b, grp.n)
+	got := jointExp(new(big.Int), A, b, v, new(big.Int).Mul(u, b), grp.n)
+	if got.Cmp(want) != 0 {
+		t.Error("A^b * v^(ub) differs from (Av^u)^b")
+	}
+}
+
+// BenchmarkServerPremaster compares the server's premaster secret as Key computes
+// it, "Exp", with the rearranged, simultaneous computation, "Straus".
+func BenchmarkServerPremaster(b *testing.B) {
+	for _, id := range knownGroupIDs() {
+		grp := KnownGroups[id]
+		A, v, u, e := premasterInputs(b, grp)
+		ue := new(big.Int).Mul(u, e)
+		z, prod := new(big.Int), new(big.Int)
+		b.Run(grp.Label+"/Exp", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				z.Exp(v, u, grp.n)
+				prod.Mul(z, A)
+				z.Mod(prod, grp.n)
+				z.Exp(z, e, grp.n)
+			}
+		})
+		b.Run(grp.Label+"/Straus", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				jointExp(z, A, e, v, ue, grp.n)
+			}
+		})
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,250 @@
+package srp
+
+import (
+	"crypto/cipher"
+	rand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+/*
+Between sending B and checking the client's proof a server has to keep its SRP,
+including the secret b. A HandshakeSealer lets it hand that state to the client
+instead, as an opaque token that the client sends back with its proof, so that
+any server holding the key can finish the handshake.
+
+A token is laid out as
+
+	version (1 byte) | len(keyID) (1 byte) | keyID | expiry (8 bytes) | nonce (12 bytes) | AES-256-GCM ciphertext
+
+where the expiry is big endian Unix seconds and the plaintext is the server's
+MarshalBinary. The header, the identity and A are the associated data, so a
+token can't be used for another identity or another A, or given a later expiry.
+
+A token can be opened only once. The sealer's ReplayCache remembers the nonces
+of the tokens it has opened until they expire, so that a token can't be
+presented again with the same b. The default remembers them in memory, which
+covers one server; servers sharing keys must share a ReplayCache too, or a
+token can be replayed once on each of them. Servers sharing keys should also
+have reasonably synchronized clocks.
+*/
+
+const (
+	tokenFormatVersion = 1
+
+	// DefaultHandshakeTokenTTL is how long a handshake token lasts if
+	// NewHandshakeSealer is given no TTL.
+	DefaultHandshakeTokenTTL = 30 * time.Second
+)
+
+var (
+	// ErrTokenExpired is returned when opening a handshake token that has expired.
+	ErrTokenExpired = errors.New("handshake token expired")
+	// ErrTokenReplayed is returned when opening a handshake token that has been opened before.
+	ErrTokenReplayed = errors.New("handshake token already used")
+)
+
+// ReplayCache remembers the handshake tokens that have been opened.
+type ReplayCache interface {
+	// Use records the token with nonce as opened, and reports whether it had
+	// been already. The nonce may be forgotten once expiry has passed.
+	Use(nonce []byte, expiry time.Time) (bool, error)
+}
+
+// MemoryReplayCache is a ReplayCache that keeps nonces in memory.
+type MemoryReplayCache struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	nonces  map[string]time.Time // expiry, by nonce
+	sweepAt int                  // sweep expired nonces once there are this many
+}
+
+var _ ReplayCache = &MemoryReplayCache{} //nolint:exhaustruct
+
+// NewMemoryReplayCache returns an empty MemoryReplayCache.
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{now: time.Now, nonces: make(map[string]time.Time), sweepAt: 1024}
+}
+
+// Use implements ReplayCache.
+func (rc *MemoryReplayCache) Use(nonce []byte, expiry time.Time) (bool, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	now := rc.now()
+	if exp, ok := rc.nonces[string(nonce)]; ok && now.Before(exp) {
+		return true, nil
+	}
+	rc.sweep(now)
+	rc.nonces[string(nonce)] = expiry
+	return false, nil
+}
+
+// sweep forgets expired nonces. rc.mu must be held.
+func (rc *MemoryReplayCache) sweep(now time.Time) {
+	if len(rc.nonces) < rc.sweepAt {
+		return
+	}
+	for nonce, expiry := range rc.nonces {
+		if !now.Before(expiry) {
+			delete(rc.nonces, nonce)
+		}
+	}
+	// Sweeping is linear, so don't do it again until the map has doubled.
+	rc.sweepAt = 2 * len(rc.nonces)
+	if rc.sweepAt < 1024 {
+		rc.sweepAt = 1024
+	}
+}
+
+// HandshakeSealer seals pending server handshakes into tokens and opens them.
+// Like VerifierSealer, it seals under its current key and opens with any of its keys.
+type HandshakeSealer struct {
+	currentKeyID string
+	aeads        map[string]cipher.AEAD
+	ttl          time.Duration
+	now          func() time.Time
+
+	// Replay remembers the tokens that have been opened. NewHandshakeSealer sets
+	// it to a new MemoryReplayCache; replace it with a shared one when several
+	// servers share keys.
+	Replay ReplayCache
+}
+
+// NewHandshakeSealer creates a HandshakeSealer from a map of key IDs to 32 byte keys.
+// currentKeyID names the key used for sealing and must be in keys. Tokens expire
+// ttl after they are sealed; zero means DefaultHandshakeTokenTTL.
+// The keys should not also be used for a VerifierSealer.
+func NewHandshakeSealer(currentKeyID string, keys map[string][]byte, ttl time.Duration) (*HandshakeSealer, error) {
+	if _, ok := keys[currentKeyID]; !ok {
+		return nil, fmt.Errorf("current key %q not among keys", currentKeyID)
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("negative token TTL")
+	}
+	if ttl == 0 {
+		ttl = DefaultHandshakeTokenTTL
+	}
+	aeads, err := newKeyedAEADs(keys)
+	if err != nil {
+		return nil, err
+	}
+	return &HandshakeSealer{currentKeyID: currentKeyID, aeads: aeads, ttl: ttl, now: time.Now, Replay: NewMemoryReplayCache()}, nil
+}
+
+/*
+Seal returns a token holding the state of server, which must be a server that
+has been given the client's A but hasn't yet checked the client's proof.
+identity is the identity the client claims; Open must be given the same one.
+*/
+func (hs *HandshakeSealer) Seal(server *SRP, identity string) ([]byte, error) {
+	if server == nil || !server.isServer {
+		return nil, fmt.Errorf("only a server's handshake can be sealed")
+	}
+	if server.badState {
+		return nil, fmt.Errorf("we have bad data")
+	}
+	if server.ephemeralPublicA.Sign() == 0 {
+		return nil, fmt.Errorf("client's public exponent not set")
+	}
+	if server.isClientProved {
+		return nil, fmt.Errorf("handshake is already complete")
+	}
+	state, err := server.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	aead := hs.aeads[hs.currentKeyID]
+
+	header := []byte{tokenFormatVersion, byte(len(hs.currentKeyID))}
+	header = append(header, hs.currentKeyID...)
+	var expiry [8]byte
+	binary.BigEndian.PutUint64(expiry[:], uint64(hs.now().Add(hs.ttl).Unix()))
+	header = append(header, expiry[:]...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// If we can't get random bytes from the system, then we have no business doing anything crypto related.
+		panic(fmt.Sprintf("Failed to get random bytes: %v", err))
+	}
+
+	token := make([]byte, 0, len(header)+len(nonce)+len(state)+aead.Overhead())
+	token = append(token, header...)
+	token = append(token, nonce...)
+	return aead.Seal(token, nonce, state, tokenAD(header, identity, server.ephemeralPublicA)), nil
+}
+
+// Open returns the server sealed in token, provided that the token hasn't
+// expired or been opened before, and was sealed for identity and the client's A.
+//
+//nolint:gocritic // A != a. Case matters
+func (hs *HandshakeSealer) Open(token []byte, identity string, A *big.Int) (*SRP, error) {
+	if len(token) < 2 {
+		return nil, fmt.Errorf("handshake token too short")
+	}
+	if token[0] != tokenFormatVersion {
+		return nil, fmt.Errorf("unknown handshake token format %d", token[0])
+	}
+	idLen := int(token[1])
+	if len(token) < 2+idLen+8 {
+		return nil, fmt.Errorf("handshake token too short")
+	}
+	keyID := string(token[2 : 2+idLen])
+	aead, ok := hs.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSealingKey, keyID)
+	}
+	headerLen := 2 + idLen + 8
+	if len(token) < headerLen+aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("handshake token too short")
+	}
+	header := token[:headerLen]
+	nonce := token[headerLen : headerLen+aead.NonceSize()]
+	ciphertext := token[headerLen+aead.NonceSize():]
+
+	if A == nil {
+		return nil, fmt.Errorf("no public exponent")
+	}
+	state, err := aead.Open(nil, nonce, ciphertext, tokenAD(header, identity, A))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open handshake token: %w", err)
+	}
+	// Checked after authentication, so that the expiry can be trusted.
+	expiry := int64(binary.BigEndian.Uint64(header[headerLen-8:]))
+	if hs.now().Unix() >= expiry {
+		return nil, ErrTokenExpired
+	}
+	used, err := hs.Replay.Use(nonce, time.Unix(expiry, 0))
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, ErrTokenReplayed
+	}
+
+	server := &SRP{}
+	if err := server.UnmarshalBinary(state); err != nil {
+		return nil, err
+	}
+	if !server.isServer || server.ephemeralPublicA.Cmp(A) != 0 {
+		return nil, fmt.Errorf("handshake token holds the wrong state")
+	}
+	return server, nil
+}
+
+//nolint:gocritic // A != a. Case matters
+func tokenAD(header []byte, identity string, A *big.Int) []byte {
+	ad := make([]byte, 0, len(header)+4+len(identity)+len(A.Bytes()))
+	ad = append(ad, header...)
+	ad = appendUint32(ad, uint32(len(identity)))
+	ad = append(ad, identity...)
+	return append(ad, A.Bytes()...)
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,165 @@
+package srp
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestHandshakeToken(t *testing.T) {
+	keys := map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, sealKeySize),
+		"k2": bytes.Repeat([]byte{2}, sealKeySize),
+	}
+	sealer, err := NewHandshakeSealer("k1", keys, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another server instance, with the same keys but now sealing under a new one
+	other, err := NewHandshakeSealer("k2", keys, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	sealer.now = func() time.Time { return now }
+	other.now = sealer.now
+	replay := NewMemoryReplayCache()
+	replay.now = sealer.now
+	sealer.Replay, other.Replay = replay, replay
+
+	grp := KnownGroups[RFC5054Group3072]
+	x := big.NewInt(0xabcdef)
+	v, _ := NewClientStd(grp, x).Verifier()
+	client := NewClientStd(grp, x)
+	server := NewServerStd(grp, v)
+	A := client.EphemeralPublic()
+	if err := server.SetOthersPublic(A); err != nil {
+		t.Fatal(err)
+	}
+	token, err := sealer.Seal(server, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(token, server.ephemeralPrivate.Bytes()) {
+		t.Error("token contains b")
+	}
+
+	resumed, err := other.Open(token, "alice", A)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetOthersPublic(resumed.EphemeralPublic()); err != nil {
+		t.Fatal(err)
+	}
+	clientKey, err := client.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverKey, err := resumed.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(clientKey, serverKey) {
+		t.Error("resumed server's key doesn't match the client's")
+	}
+	salt := []byte("salt")
+	m, err := resumed.M(salt, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.GoodServerProof(salt, "alice", m) {
+		t.Error("client rejected the resumed server's proof")
+	}
+	proof, _ := client.ClientProof()
+	if !resumed.GoodClientProof(proof) {
+		t.Error("resumed server rejected the client's proof")
+	}
+
+	// Neither instance opens it again.
+	for _, hs := range []*HandshakeSealer{sealer, other} {
+		if _, err := hs.Open(token, "alice", A); !errors.Is(err, ErrTokenReplayed) {
+			t.Errorf("expected ErrTokenReplayed, got %v", err)
+		}
+	}
+
+	if _, err := other.Open(token, "bob", A); err == nil {
+		t.Error("opened token for another identity")
+	}
+	if _, err := other.Open(token, "alice", new(big.Int).Add(A, bigOne)); err == nil {
+		t.Error("opened token for another A")
+	}
+	for i := range token {
+		tampered := append([]byte{}, token...)
+		tampered[i] ^= 1
+		if _, err := other.Open(tampered, "alice", A); err == nil {
+			t.Errorf("opened token with byte %d changed", i)
+		}
+	}
+	if _, err := other.Open(token[:len(token)-1], "alice", A); err == nil {
+		t.Error("opened truncated token")
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := other.Open(token, "alice", A); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired, got %v", err)
+	}
+
+	onlyK2, _ := NewHandshakeSealer("k2", map[string][]byte{"k2": keys["k2"]}, 0)
+	if _, err := onlyK2.Open(token, "alice", A); !errors.Is(err, ErrUnknownSealingKey) {
+		t.Errorf("expected ErrUnknownSealingKey, got %v", err)
+	}
+}
+
+func TestMemoryReplayCache(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	rc := NewMemoryReplayCache()
+	rc.now = func() time.Time { return now }
+	use := func(nonce string, expiry time.Time, want bool) {
+		t.Helper()
+		used, err := rc.Use([]byte(nonce), expiry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used != want {
+			t.Errorf("Use(%q) = %v, want %v", nonce, used, want)
+		}
+	}
+	use("a", now.Add(time.Minute), false)
+	use("a", now.Add(time.Minute), true)
+	use("b", now.Add(time.Second), false)
+	now = now.Add(time.Second)
+	use("a", now.Add(time.Minute), true)
+	use("b", now.Add(time.Second), false) // expired, so forgotten
+
+	rc.sweepAt = 3
+	use("c", now.Add(time.Minute), false)
+	now = now.Add(time.Hour)
+	use("d", now.Add(time.Minute), false)
+	if len(rc.nonces) != 1 {
+		t.Errorf("%d nonces left after sweeping", len(rc.nonces))
+	}
+}
+
+func TestHandshakeTokenSealBadState(t *testing.T) {
+	sealer, err := NewHandshakeSealer("k", map[string][]byte{"k": make([]byte, sealKeySize)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grp := KnownGroups[RFC5054Group2048]
+	if _, err := sealer.Seal(NewClientStd(grp, big.NewInt(5)), "alice"); err == nil {
+		t.Error("sealed a client")
+	}
+	if _, err := sealer.Seal(NewServerStd(grp, big.NewInt(5)), "alice"); err == nil {
+		t.Error("sealed a server without A")
+	}
+	if _, err := NewHandshakeSealer("k", map[string][]byte{"k": make([]byte, 16)}, 0); err == nil {
+		t.Error("accepted short key")
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,128 @@
+package srp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+/*
+Verifiers enrolled with a small group or a cheap KDF can be upgraded the next
+time their owner logs in, without the owner noticing. After a successful
+handshake the server checks the record against its UpgradePolicy and, if the
+record falls short, asks the client to re-enroll:
+
+	Server: (after GoodClientProof)
+	        if params, ok := policy.Upgrade(rec); ok {
+	            U = server.SealUpgradeRequest(I, params)
+	        }
+	Server -> Client: U
+
+	Client: params = client.OpenUpgradeRequest(I, U)
+	        x' = KDF(params.KDF, s', password)
+	        v' = g'^x' for g' of params.GroupID
+	        C  = client.SealPasswordChange(I, s', params.GroupID, params.KDF, v')
+	Client -> Server: C
+
+	Server: UpgradeVerifier(store, server, rec, policy, C)
+
+Both U and C are sealed with the session key, so a man in the middle can
+neither downgrade the requested parameters nor substitute a verifier.
+*/
+
+// upgradeRequestLabel is used with sealWithSessionKey.
+const upgradeRequestLabel = "SRP upgrade request v1"
+
+// UpgradeParams are the parameters a client is asked to re-enroll with.
+type UpgradeParams struct {
+	GroupID int       `json:"group"`
+	KDF     KDFParams `json:"kdf"`
+}
+
+// UpgradePolicy decides whether a verifier record should be re-enrolled.
+type UpgradePolicy interface {
+	// Upgrade returns the parameters that rec should be re-enrolled with and true,
+	// or false if rec is good enough as it is.
+	Upgrade(rec *VerifierRecord) (UpgradeParams, bool)
+}
+
+// MinimumPolicy is an UpgradePolicy that asks for an upgrade whenever a record
+// uses a smaller group, a different KDF algorithm or fewer KDF iterations than
+// its own. Records are upgraded to exactly the policy's parameters.
+type MinimumPolicy UpgradeParams
+
+var _ UpgradePolicy = MinimumPolicy{} //nolint:exhaustruct
+
+// Upgrade implements UpgradePolicy.
+func (p MinimumPolicy) Upgrade(rec *VerifierRecord) (UpgradeParams, bool) {
+	want := UpgradeParams(p)
+	have := rec.Group()
+	target := KnownGroups[p.GroupID]
+	if have == nil || (target != nil && have.n.BitLen() < target.n.BitLen()) {
+		return want, true
+	}
+	if rec.KDF.Alg != p.KDF.Alg || rec.KDF.Iterations < p.KDF.Iterations {
+		return want, true
+	}
+	return UpgradeParams{}, false
+}
+
+// SealUpgradeRequest is called by the server to ask the client to re-enroll with params.
+// It may only be called once GoodClientProof has succeeded.
+func (s *SRP) SealUpgradeRequest(identity string, params UpgradeParams) ([]byte, error) {
+	if !s.isServer {
+		return nil, fmt.Errorf("only the server may request an upgrade")
+	}
+	if !s.isClientProved {
+		return nil, fmt.Errorf("don't request an upgrade until client is proved")
+	}
+	plaintext, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode upgrade request: %w", err)
+	}
+	return s.sealWithSessionKey(upgradeRequestLabel, identity, plaintext)
+}
+
+// OpenUpgradeRequest is called by the client to open an upgrade request from the server.
+// The client should derive a new x with a fresh salt and the returned parameters
+// and send the resulting verifier with SealPasswordChange.
+func (s *SRP) OpenUpgradeRequest(identity string, sealed []byte) (UpgradeParams, error) {
+	var params UpgradeParams
+	if s.isServer {
+		return params, fmt.Errorf("only the client may open an upgrade request")
+	}
+	if !s.isServerProved {
+		return params, fmt.Errorf("don't accept an upgrade request until server is proved")
+	}
+	plaintext, err := s.openWithSessionKey(upgradeRequestLabel, identity, sealed)
+	if err != nil {
+		return params, fmt.Errorf("failed to open upgrade request: %w", err)
+	}
+	if err := json.Unmarshal(plaintext, &params); err != nil {
+		return params, fmt.Errorf("failed to decode upgrade request: %w", err)
+	}
+	if grp := KnownGroups[params.GroupID]; grp == nil || grp.n.BitLen() < MinGroupSize {
+		return params, fmt.Errorf("unacceptable group %d in upgrade request", params.GroupID)
+	}
+	return params, nil
+}
+
+/*
+UpgradeVerifier applies a re-enrollment sent in response to an upgrade request.
+It is ChangePassword with the additional check that the new record satisfies
+policy, so a client can't answer an upgrade request with parameters that are no
+better than the ones it had.
+*/
+func UpgradeVerifier(ctx context.Context, store VerifierStore, server *SRP, rec *VerifierRecord, policy UpgradePolicy, sealed []byte) error {
+	return applyPasswordChange(ctx, store, server, rec, sealed, func(changed *VerifierRecord) error {
+		if _, stillNeeded := policy.Upgrade(changed); stillNeeded {
+			return fmt.Errorf("%w: re-enrollment doesn't satisfy upgrade policy", ErrBadCredentialChange)
+		}
+		return nil
+	})
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,105 @@
+package srp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMinimumPolicy(t *testing.T) {
+	policy := MinimumPolicy{GroupID: RFC5054Group4096, KDF: KDFParams{Alg: "rfc5054", Iterations: 10}}
+
+	type testCase struct {
+		rec     VerifierRecord
+		upgrade bool
+	}
+	cases := []testCase{
+		{VerifierRecord{GroupID: RFC5054Group2048, KDF: KDFParams{Alg: "rfc5054", Iterations: 10}}, true},
+		{VerifierRecord{GroupID: RFC5054Group4096, KDF: KDFParams{Alg: "rfc5054", Iterations: 9}}, true},
+		{VerifierRecord{GroupID: RFC5054Group4096, KDF: KDFParams{Alg: "other", Iterations: 10}}, true},
+		{VerifierRecord{GroupID: RFC5054Group4096, KDF: KDFParams{Alg: "rfc5054", Iterations: 10}}, false},
+		{VerifierRecord{GroupID: RFC5054Group8192, KDF: KDFParams{Alg: "rfc5054", Iterations: 20}}, false},
+		{VerifierRecord{GroupID: 999, KDF: KDFParams{Alg: "rfc5054", Iterations: 20}}, true},
+	}
+	for i, c := range cases {
+		params, upgrade := policy.Upgrade(&c.rec)
+		if upgrade != c.upgrade {
+			t.Errorf("case %d: expected upgrade %t, got %t", i, c.upgrade, upgrade)
+		}
+		if upgrade && params != UpgradeParams(policy) {
+			t.Errorf("case %d: upgrade to %+v", i, params)
+		}
+	}
+}
+
+func TestUpgradeOnLogin(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	identity := "alice"
+	password := "password123"
+	rec := enroll(t, store, identity, password, RFC5054Group2048)
+	policy := MinimumPolicy{GroupID: RFC5054Group3072, KDF: KDFParams{Alg: "rfc5054", Iterations: 2}}
+
+	client, server := authenticatedPair(t, rec, password)
+
+	params, ok := policy.Upgrade(rec)
+	if !ok {
+		t.Fatal("policy didn't ask for upgrade")
+	}
+	request, err := server.SealUpgradeRequest(identity, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.OpenUpgradeRequest(identity, request)
+	if err != nil {
+		t.Fatalf("client couldn't open upgrade request: %s", err)
+	}
+	if got != params {
+		t.Errorf("client got params %+v instead of %+v", got, params)
+	}
+	tampered := append([]byte(nil), request...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := client.OpenUpgradeRequest(identity, tampered); err == nil {
+		t.Error("client accepted tampered upgrade request")
+	}
+
+	newSalt := []byte("fresh salt")
+	newV, err := NewClientStd(KnownGroups[got.GroupID], KDFRFC5054(newSalt, identity, password)).Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	change, err := client.SealPasswordChange(identity, newSalt, got.GroupID, got.KDF, newV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpgradeVerifier(ctx, store, server, rec, policy, change); err != nil {
+		t.Fatalf("upgrade failed: %s", err)
+	}
+	if _, ok := policy.Upgrade(rec); ok {
+		t.Error("upgraded record still needs upgrade")
+	}
+	authenticatedPair(t, rec, password)
+}
+
+func TestUpgradeRejectsNonCompliance(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	rec := enroll(t, store, "alice", "password123", RFC5054Group2048)
+	policy := MinimumPolicy{GroupID: RFC5054Group3072, KDF: KDFParams{Alg: "rfc5054", Iterations: 2}}
+
+	client, server := authenticatedPair(t, rec, "password123")
+
+	// Client re-enrolls with the same old parameters
+	change, err := client.SealPasswordChange(rec.Identity, []byte("salt"), rec.GroupID, rec.KDF, rec.Verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpgradeVerifier(ctx, store, server, rec, policy, change); err == nil {
+		t.Error("accepted re-enrollment that doesn't satisfy policy")
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -6,6 +6,8 @@ import (
 )
 
 // NumberFromString converts a string (hex) to a number.
+// It is for trusted constants such as group parameters: anything malformed
+// silently becomes 0. Parse anything from outside with ParseNumber.
 func NumberFromString(s string) *big.Int {
 	n := strings.ReplaceAll(s, " ", "")
 
@@ -0,0 +1,352 @@
+package srp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"unicode/utf8"
+)
+
+/*
+The handshake messages have a binary encoding so that parties don't each invent
+their own. A message is
+
+	version  1 byte, WireVersion
+	type     1 byte
+	length   big endian uint32, the length of the body
+	body     a fixed number of fields for the type, each a big endian uint16
+	         length and that many bytes
+
+Numbers (A and B) are big endian without leading zeros. Proofs are SHA-256 hashes.
+The messages are listed in the order of a handshake; the client proves itself
+before the server does.
+
+	ClientHello      identity, credential, group label, A
+	ServerChallenge  salt, group label, KDF algorithm, KDF iterations (uint32), B
+	ClientProof      client proof
+	ServerProof      M
+	ErrorMessage     message, group label
+
+Decoding is strict: the version, type, field count and every field length must
+be exactly right, strings must be UTF-8 and there may be nothing left over.
+Every field has a size limit, checked before anything is allocated for it, so
+a peer can't make the other parse huge integers before IsPublicValid sees them.
+*/
+
+// WireVersion is the version of the encoding written by MarshalMessage.
+const WireVersion = 1
+
+// Limits on the fields of messages.
+const (
+	MaxIdentitySize = 1024
+	MaxLabelSize    = 256 // credential names, group labels and KDF algorithms
+	MaxSaltSize     = 256
+	MaxNumberSize   = 1024 // enough for an 8192 bit group
+	MaxErrorSize    = 1024
+
+	// MaxWireMessageSize is the largest body of any message.
+	MaxWireMessageSize = 4 * 1024
+)
+
+const (
+	wireHeaderSize = 6
+	proofSize      = 32 // SHA-256
+
+	wireClientHello     byte = 1
+	wireServerChallenge byte = 2
+	wireClientProof     byte = 3
+	wireServerProof     byte = 4
+	wireError           byte = 5
+)
+
+// ErrMalformedMessage is returned for messages that don't decode.
+var ErrMalformedMessage = errors.New("malformed message")
+
+// Message is one of the handshake messages:
+// *ClientHello, *ServerChallenge, *ClientProof, *ServerProof or *ErrorMessage.
+type Message interface {
+	wireType() byte
+	fields() [][]byte
+	setFields(f [][]byte)
+}
+
+// ClientHello starts a handshake.
+type ClientHello struct {
+	Identity   string
+	Credential string
+	Group      string // label of the group A is in
+	A          *big.Int
+}
+
+// ServerChallenge answers a ClientHello.
+type ServerChallenge struct {
+	Salt  []byte
+	Group string
+	KDF   KDFParams
+	B     *big.Int
+}
+
+// ClientProof carries the client's proof.
+type ClientProof struct {
+	Proof []byte
+}
+
+// ServerProof carries the server's proof, M, sent once the client's has been accepted.
+type ServerProof struct {
+	Proof []byte
+}
+
+// ErrorMessage reports a failure. Group is set when a client has picked the wrong group.
+type ErrorMessage struct {
+	Message string
+	Group   string
+}
+
+func (*ClientHello) wireType() byte     { return wireClientHello }
+func (*ServerChallenge) wireType() byte { return wireServerChallenge }
+func (*ClientProof) wireType() byte     { return wireClientProof }
+func (*ServerProof) wireType() byte     { return wireServerProof }
+func (*ErrorMessage) wireType() byte    { return wireError }
+
+// field checks a field and its size limit.
+type field struct {
+	name string
+	max  int
+	kind fieldKind
+}
+
+type fieldKind int
+
+const (
+	kindBytes fieldKind = iota
+	kindString
+	kindNumber
+	kindUint32
+	kindProof
+)
+
+var wireFields = map[byte][]field{
+	wireClientHello: {
+		{"identity", MaxIdentitySize, kindString},
+		{"credential", MaxLabelSize, kindString},
+		{"group", MaxLabelSize, kindString},
+		{"A", MaxNumberSize, kindNumber},
+	},
+	wireServerChallenge: {
+		{"salt", MaxSaltSize, kindBytes},
+		{"group", MaxLabelSize, kindString},
+		{"KDF algorithm", MaxLabelSize, kindString},
+		{"KDF iterations", 4, kindUint32},
+		{"B", MaxNumberSize, kindNumber},
+	},
+	wireClientProof: {{"proof", proofSize, kindProof}},
+	wireServerProof: {{"proof", proofSize, kindProof}},
+	wireError: {
+		{"message", MaxErrorSize, kindString},
+		{"group", MaxLabelSize, kindString},
+	},
+}
+
+func (f field) check(b []byte) error {
+	if len(b) > f.max {
+		return fmt.Errorf("%w: %s is %d bytes, more than %d", ErrMalformedMessage, f.name, len(b), f.max)
+	}
+	switch f.kind {
+	case kindString:
+		if !utf8.Valid(b) {
+			return fmt.Errorf("%w: %s isn't UTF-8", ErrMalformedMessage, f.name)
+		}
+	case kindNumber:
+		if len(b) == 0 || b[0] == 0 {
+			return fmt.Errorf("%w: %s is zero or has leading zeros", ErrMalformedMessage, f.name)
+		}
+	case kindUint32, kindProof:
+		if len(b) != f.max {
+			return fmt.Errorf("%w: %s is %d bytes, not %d", ErrMalformedMessage, f.name, len(b), f.max)
+		}
+	case kindBytes:
+	}
+	return nil
+}
+
+func numberBytes(n *big.Int) []byte {
+	if n == nil {
+		return nil
+	}
+	return n.Bytes()
+}
+
+func (m *ClientHello) fields() [][]byte {
+	return [][]byte{[]byte(m.Identity), []byte(m.Credential), []byte(m.Group), numberBytes(m.A)}
+}
+
+func (m *ClientHello) setFields(f [][]byte) {
+	m.Identity, m.Credential, m.Group = string(f[0]), string(f[1]), string(f[2])
+	m.A = new(big.Int).SetBytes(f[3])
+}
+
+func (m *ServerChallenge) fields() [][]byte {
+	var iterations [4]byte
+	binary.BigEndian.PutUint32(iterations[:], m.KDF.Iterations)
+	return [][]byte{m.Salt, []byte(m.Group), []byte(m.KDF.Alg), iterations[:], numberBytes(m.B)}
+}
+
+func (m *ServerChallenge) setFields(f [][]byte) {
+	m.Salt = append([]byte{}, f[0]...)
+	m.Group = string(f[1])
+	m.KDF = KDFParams{Alg: string(f[2]), Iterations: binary.BigEndian.Uint32(f[3])}
+	m.B = new(big.Int).SetBytes(f[4])
+}
+
+func (m *ClientProof) fields() [][]byte { return [][]byte{m.Proof} }
+
+func (m *ClientProof) setFields(f [][]byte) {
+	m.Proof = append([]byte{}, f[0]...)
+}
+
+func (m *ServerProof) fields() [][]byte { return [][]byte{m.Proof} }
+
+func (m *ServerProof) setFields(f [][]byte) {
+	m.Proof = append([]byte{}, f[0]...)
+}
+
+func (m *ErrorMessage) fields() [][]byte {
+	return [][]byte{[]byte(m.Message), []byte(m.Group)}
+}
+
+func (m *ErrorMessage) setFields(f [][]byte) {
+	m.Message, m.Group = string(f[0]), string(f[1])
+}
+
+// MarshalMessage encodes m. It refuses to encode anything UnmarshalMessage would reject.
+func MarshalMessage(m Message) ([]byte, error) {
+	typ := m.wireType()
+	values := m.fields()
+	spec := wireFields[typ]
+	out := make([]byte, wireHeaderSize, wireHeaderSize+64)
+	out[0], out[1] = WireVersion, typ
+	for i, v := range values {
+		if err := spec[i].check(v); err != nil {
+			return nil, err
+		}
+		var n [2]byte
+		binary.BigEndian.PutUint16(n[:], uint16(len(v)))
+		out = append(out, n[:]...)
+		out = append(out, v...)
+	}
+	if len(out)-wireHeaderSize > MaxWireMessageSize {
+		return nil, fmt.Errorf("message too large")
+	}
+	binary.BigEndian.PutUint32(out[2:wireHeaderSize], uint32(len(out)-wireHeaderSize))
+	return out, nil
+}
+
+// UnmarshalMessage decodes exactly one message from data.
+func UnmarshalMessage(data []byte) (Message, error) {
+	if len(data) < wireHeaderSize {
+		return nil, fmt.Errorf("%w: too short", ErrMalformedMessage)
+	}
+	m, size, err := parseHeader(data[:wireHeaderSize])
+	if err != nil {
+		return nil, err
+	}
+	if len(data)-wireHeaderSize != size {
+		return nil, fmt.Errorf("%w: length is %d, not %d", ErrMalformedMessage, len(data)-wireHeaderSize, size)
+	}
+	if err := parseBody(m, data[wireHeaderSize:]); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadMessage reads one message from r. It reads no more than the header
+// and the size that the header declares, which must be within MaxWireMessageSize.
+func ReadMessage(r io.Reader) (Message, error) {
+	var header [wireHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	m, size, err := parseHeader(header[:])
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if err := parseBody(m, body); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WriteMessage encodes m and writes it to w.
+func WriteMessage(w io.Writer, m Message) error {
+	data, err := MarshalMessage(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func parseHeader(header []byte) (Message, int, error) {
+	if header[0] != WireVersion {
+		return nil, 0, fmt.Errorf("%w: unsupported version %d", ErrMalformedMessage, header[0])
+	}
+	var m Message
+	switch header[1] {
+	case wireClientHello:
+		m = &ClientHello{}
+	case wireServerChallenge:
+		m = &ServerChallenge{}
+	case wireClientProof:
+		m = &ClientProof{}
+	case wireServerProof:
+		m = &ServerProof{}
+	case wireError:
+		m = &ErrorMessage{}
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown type %d", ErrMalformedMessage, header[1])
+	}
+	size := binary.BigEndian.Uint32(header[2:])
+	if size > MaxWireMessageSize {
+		return nil, 0, fmt.Errorf("%w: body of %d bytes is too large", ErrMalformedMessage, size)
+	}
+	return m, int(size), nil
+}
+
+func parseBody(m Message, body []byte) error {
+	spec := wireFields[m.wireType()]
+	values := make([][]byte, len(spec))
+	for i, f := range spec {
+		if len(body) < 2 {
+			return fmt.Errorf("%w: missing %s", ErrMalformedMessage, f.name)
+		}
+		size := int(binary.BigEndian.Uint16(body))
+		body = body[2:]
+		if size > len(body) {
+			return fmt.Errorf("%w: %s runs past the end", ErrMalformedMessage, f.name)
+		}
+		if err := f.check(body[:size]); err != nil {
+			return err
+		}
+		values[i] = body[:size]
+		body = body[size:]
+	}
+	if len(body) != 0 {
+		return fmt.Errorf("%w: %d bytes left over", ErrMalformedMessage, len(body))
+	}
+	m.setFields(values)
+	return nil
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
@@ -0,0 +1,51 @@
+//go:build go1.18
+// +build go1.18
+
+package srp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzUnmarshalMessage(f *testing.F) {
+	for _, m := range sampleMessages() {
+		data, err := MarshalMessage(m)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := UnmarshalMessage(data)
+		if err != nil {
+			return
+		}
+		// Anything accepted must be in its one canonical encoding.
+		again, err := MarshalMessage(m)
+		if err != nil {
+			t.Fatalf("accepted message doesn't marshal: %s", err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Fatalf("encoding isn't canonical:\n%x\n%x", data, again)
+		}
+	})
+}
+
+func FuzzReadMessage(f *testing.F) {
+	for _, m := range sampleMessages() {
+		data, _ := MarshalMessage(m)
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bytes.NewReader(data)
+		if _, err := ReadMessage(r); err == nil && int(r.Size())-r.Len() > MaxWireMessageSize+wireHeaderSize {
+			t.Fatalf("read %d bytes", int(r.Size())-r.Len())
+		}
+	})
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/
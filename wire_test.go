@@ -0,0 +1,79 @@
+package srp
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func sampleMessages() []Message {
+	proof := bytes.Repeat([]byte{0xab}, 32)
+	return []Message{
+		&ClientHello{Identity: "alice", Credential: "device-1", Group: "5054A2048", A: big.NewInt(0x123456)},
+		&ServerChallenge{Salt: []byte("salt"), Group: "5054A2048", KDF: KDFParams{Alg: "rfc5054", Iterations: 1}, B: big.NewInt(0x654321)},
+		&ServerProof{Proof: proof},
+		&ClientProof{Proof: proof},
+		&ErrorMessage{Message: "wrong group", Group: "5054A4096"},
+	}
+}
+
+func TestWireRoundTrip(t *testing.T) {
+	for _, m := range sampleMessages() {
+		data, err := MarshalMessage(m)
+		if err != nil {
+			t.Fatalf("failed to marshal %T: %s", m, err)
+		}
+		got, err := UnmarshalMessage(data)
+		if err != nil {
+			t.Fatalf("failed to unmarshal %T: %s", m, err)
+		}
+		if !reflect.DeepEqual(m, got) {
+			t.Errorf("round trip of %T gave %+v", m, got)
+		}
+		read, err := ReadMessage(bytes.NewReader(data))
+		if err != nil || !reflect.DeepEqual(m, read) {
+			t.Errorf("ReadMessage of %T gave %+v, %v", m, read, err)
+		}
+	}
+}
+
+func TestWireStrict(t *testing.T) {
+	good, _ := MarshalMessage(&ClientProof{Proof: make([]byte, 32)})
+
+	bad := map[string][]byte{
+		"empty":         {},
+		"trailing byte": append(append([]byte{}, good...), 0),
+		"truncated":     good[:len(good)-1],
+		"version":       append([]byte{2}, good[1:]...),
+		"type":          append([]byte{1, 99}, good[2:]...),
+	}
+	for name, data := range bad {
+		if _, err := UnmarshalMessage(data); !errors.Is(err, ErrMalformedMessage) {
+			t.Errorf("%s: expected ErrMalformedMessage, got %v", name, err)
+		}
+	}
+
+	// The declared length is checked before anything is read
+	huge := []byte{WireVersion, wireClientHello, 0x7f, 0xff, 0xff, 0xff}
+	if _, err := ReadMessage(bytes.NewReader(huge)); !errors.Is(err, ErrMalformedMessage) {
+		t.Errorf("huge message: expected ErrMalformedMessage, got %v", err)
+	}
+
+	tooBig := new(big.Int).Lsh(bigOne, 8*MaxNumberSize)
+	if _, err := MarshalMessage(&ClientHello{Identity: "alice", A: tooBig}); err == nil {
+		t.Error("marshaled oversized A")
+	}
+	if _, err := MarshalMessage(&ClientHello{Identity: "alice"}); err == nil {
+		t.Error("marshaled missing A")
+	}
+	if _, err := MarshalMessage(&ServerProof{Proof: []byte{1}}); err == nil {
+		t.Error("marshaled short proof")
+	}
+}
+
+/**
+ ** Copyright 2022 AgileBits, Inc.
+ ** Licensed under the Apache License, Version 2.0 (the "License").
+ **/